@@ -5,8 +5,8 @@ package alog
 import (
 	"encoding/json"
 	"io"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,7 +28,14 @@ const (
 	F_DATE
 	F_USE_BUF_1K
 	F_USE_BUF_2K
-	F_STD = F_MMDD | F_TIME | F_PREFIX
+	F_JSON
+	F_LOGFMT
+	F_LEVEL      // print a level tag such as `[INF]`
+	F_LEVEL_FULL // print a level tag with its full name such as `[INFO]`
+	F_CALLER     // print the caller's file name and line such as `main.go:12`
+	F_LONGFILE   // print the caller's full file path and line; overrides F_CALLER
+	F_FUNC       // print the caller's function name such as `main.run`
+	F_STD        = F_MMDD | F_TIME | F_PREFIX
 )
 
 // ========================
@@ -42,25 +49,141 @@ const (
 	WARN
 	ERROR
 	FATAL
-	ALL = DEBUG | INFO | WARN | ERROR | FATAL
+	PANIC
+	ALL = DEBUG | INFO | WARN | ERROR | FATAL | PANIC
 )
 
+// String returns an uppercase level name such as `INFO`
+func (lvl Level) String() string {
+	switch lvl {
+	case DEBUG:
+		return "DEBUG"
+	case INFO:
+		return "INFO"
+	case WARN:
+		return "WARN"
+	case ERROR:
+		return "ERROR"
+	case FATAL:
+		return "FATAL"
+	case PANIC:
+		return "PANIC"
+	}
+	return ""
+}
+
+// tag returns a level tag used by text output
+func (lvl Level) tag(full bool) string {
+	if full {
+		switch lvl {
+		case DEBUG:
+			return "[DEBUG]"
+		case INFO:
+			return "[INFO]"
+		case WARN:
+			return "[WARN]"
+		case ERROR:
+			return "[ERROR]"
+		case FATAL:
+			return "[FATAL]"
+		case PANIC:
+			return "[PANIC]"
+		}
+		return ""
+	}
+	switch lvl {
+	case DEBUG:
+		return "[DBG]"
+	case INFO:
+		return "[INF]"
+	case WARN:
+		return "[WRN]"
+	case ERROR:
+		return "[ERR]"
+	case FATAL:
+		return "[FTL]"
+	case PANIC:
+		return "[PNC]"
+	}
+	return ""
+}
+
+// name returns a lowercase level name used by structured outputs
+func (lvl Level) name() string {
+	switch lvl {
+	case DEBUG:
+		return "debug"
+	case INFO:
+		return "info"
+	case WARN:
+		return "warn"
+	case ERROR:
+		return "error"
+	case FATAL:
+		return "fatal"
+	case PANIC:
+		return "panic"
+	}
+	return ""
+}
+
 // =====================================================================================================================
 // A LOGGER
 // =====================================================================================================================
 type ALogger struct {
-	out io.Writer
-	// primary buffer
-	buf          []byte
-	bufUseBuffer bool
-	bufSize      int
+	*core   // shared with child loggers created by With and WithPrefix
+	prefix  []byte
+	preset  *preset  // fields added by With; nil if none
+	ctxBase *ctxBase // from FromContext; fields of the context are already in preset
+}
+
+// core is everything shared by a logger and its children; guarded by mu.
+type core struct {
+	// levels of any additional output; read without mu by the level check of print methods, so
+	// accessed atomically. It's the first field to be 64-bit aligned for atomic on 32-bit platforms.
+	outLvl Level
+
+	sink // the logger's own output; out, flag, buffer and async queue
+
 	// secondary buffer
 	buf2    tinyBuffer
+	msg     []byte // message buffer
 	mu      sync.Mutex
-	prefix  []byte
 	jsonEnc *json.Encoder
-	flag    Format
+	encGen  uint64 // incremented when an encoder may change; see preset
 	lvl     Level
+	fprec   int // default precision of %f
+
+	// flush
+	ticker   *flushTicker // nil unless SetFlushInterval is used
+	flushLvl Level        // levels written out immediately
+
+	// additional outputs
+	outputs []*Output
+	outFlag Format // flags of any additional output
+
+	// write errors; errMu is separate as async queues report errors without l.mu
+	errMu    sync.Mutex
+	errCount uint64
+	errLast  error
+	errFn    ErrorHandler
+	fallback io.Writer
+
+	// caller
+	callerSkip  int
+	callers     map[uintptr]Caller // resolved pc
+	stackLvl    Level              // levels with a stack trace; see SetStackLevel
+	stackPCs    []uintptr
+	stack       Stack
+	stackFields []Field // fields with the stack
+
+	// context
+	ctxHooks  []ContextHook
+	ctxFields []Field // fields buffer of `*Ctx` methods
+
+	// exit
+	exitFn    func(code int)
+	exitHooks []func()
 }
 
 func New(output io.Writer, prefix string, flag Format) *ALogger {
@@ -68,22 +191,15 @@ func New(output io.Writer, prefix string, flag Format) *ALogger {
 		output = Discard
 	}
 	l := &ALogger{
-		// buf:    make([]byte, 1024),
-		out:    output,
+		core: &core{
+			lvl:   INFO | WARN | ERROR | FATAL | PANIC,
+			fprec: defaultFloatPrec,
+		},
 		prefix: []byte(prefix),
-		flag:   flag,
-		lvl:    INFO | WARN | ERROR | FATAL,
-	}
-	if flag&(F_USE_BUF_2K|F_USE_BUF_1K) > 0 {
-		if flag&F_USE_BUF_2K > 0 {
-			l.bufSize = 2048 * 2
-		} else if flag&F_USE_BUF_1K > 0 {
-			l.bufSize = 1024 * 2
-		}
-		l.buf = make([]byte, l.bufSize)
-		l.buf = l.buf[:0]
-		l.bufUseBuffer = true
 	}
+	l.sink = sink{c: l.core, flag: flag}
+	l.setOutput(output)
+	l.setBufferSize(bufSizeOf(flag))
 
 	return l
 }
@@ -93,7 +209,7 @@ func New(output io.Writer, prefix string, flag Format) *ALogger {
 // =====================================================================================================================
 func (l *ALogger) SetOutput(output io.Writer) {
 	l.mu.Lock()
-	l.out = output
+	l.setOutput(output)
 	l.mu.Unlock()
 }
 func (l *ALogger) SetPrefix(s string) {
@@ -104,6 +220,64 @@ func (l *ALogger) SetPrefix(s string) {
 func (l *ALogger) SetFlag(flag Format) {
 	l.mu.Lock()
 	l.flag = flag
+	l.encGen++
+	l.setOutputUTC()
+	l.mu.Unlock()
+}
+
+// outLevels returns levels of any additional output
+func (l *ALogger) outLevels() Level {
+	return Level(atomic.LoadUint64((*uint64)(&l.outLvl)))
+}
+
+// LevelWriter is an output which needs the level of each entry such as SyslogWriter.
+// When the output is a LevelWriter, each entry is written immediately without buffering, and
+// WriteLevel is used instead of Write. lvl is 0 for unleveled calls.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(lvl Level, p []byte) (n int, err error)
+}
+
+// utcSetter is an output which needs to follow F_UTC such as RotatingFile
+type utcSetter interface {
+	SetUTC(utc bool)
+}
+
+// SetBufferSize writes entries through a buffer of n bytes; the buffer is written out when it exceeds n.
+// The current buffer is flushed first. n <= 0 turns buffering off.
+// This overrides F_USE_BUF_1K and F_USE_BUF_2K given to New.
+func (l *ALogger) SetBufferSize(n int) {
+	l.mu.Lock()
+	l.flushBuf()
+	l.setBufferSize(n)
+	l.mu.Unlock()
+}
+
+// bufSizeOf returns a buffer size by F_USE_BUF_* flags
+func bufSizeOf(flag Format) int {
+	if flag&F_USE_BUF_2K != 0 {
+		return 2048 * 2
+	}
+	if flag&F_USE_BUF_1K != 0 {
+		return 1024 * 2
+	}
+	return 0
+}
+
+// SetEncoder sets a custom encoder. When nil, a builtin encoder is chosen by
+// the flag: F_JSON, F_LOGFMT, or text by default.
+func (l *ALogger) SetEncoder(enc Encoder) {
+	l.mu.Lock()
+	l.enc = enc
+	l.encGen++
+	l.mu.Unlock()
+}
+
+// SetFloatPrecision sets number of decimal places for `%f` without a precision (default: 2).
+// A negative value uses the smallest number of digits necessary.
+func (l *ALogger) SetFloatPrecision(prec int) {
+	l.mu.Lock()
+	l.fprec = prec
 	l.mu.Unlock()
 }
 
@@ -111,8 +285,9 @@ func (l *ALogger) LvEnable(lvl Level) {
 	l.lvl = l.lvl | lvl
 }
 
+// LvIsEnabled checks if the logger or any of its additional outputs writes lvl
 func (l *ALogger) LvIsEnabled(lvl Level) bool {
-	if l.lvl&lvl != 0 {
+	if (l.lvl|l.outLevels())&lvl != 0 {
 		return true
 	}
 	return false
@@ -124,439 +299,174 @@ func (l *ALogger) LvOverride(lvl Level) {
 	l.lvl = lvl
 }
 
-// formatHeader is modified from builtin logger
-func (l *ALogger) formatHeader(buf *[]byte, t time.Time) {
-	if l.flag&(F_DATE|F_MMDD|F_TIME|F_MICROSEC) != 0 {
-		if l.flag&F_UTC != 0 {
-			t = t.UTC()
-		}
-		if l.flag&(F_DATE|F_MMDD) != 0 {
-			year, month, day := t.Date()
-			if l.flag&F_DATE != 0 {
-				itoa(buf, year, 4)
-				*buf = append(*buf, '/')
-			}
-			if l.flag&(F_DATE|F_MMDD) != 0 {
-				itoa(buf, int(month), 2)
-				*buf = append(*buf, '/')
-				itoa(buf, day, 2)
-				*buf = append(*buf, ' ')
-			}
-		}
-		if l.flag&(F_TIME|F_MICROSEC) != 0 {
-			hour, min, sec := t.Clock()
-			itoa(buf, hour, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, min, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, sec, 2)
-			if l.flag&F_MICROSEC != 0 {
-				*buf = append(*buf, '.')
-				itoa(buf, t.Nanosecond()/1e3, 6)
-			}
-			*buf = append(*buf, ' ')
-		}
-	}
-	if l.flag&F_PREFIX != 0 {
-		*buf = append(*buf, l.prefix...)
-	}
-}
+// Flush writes out the buffer, and waits until the async queue is drained.
+// Additional outputs are flushed as well.
 func (l *ALogger) Flush() {
-	if l.bufUseBuffer {
-		l.mu.Lock()
-		defer l.mu.Unlock()
-		if len(l.buf) > 0 {
-			l.out.Write(l.buf)
-			l.buf = l.buf[:0]
-		}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flush()
+	for _, o := range l.outputs {
+		o.flush()
 	}
 }
 func (l *ALogger) Printf(format string, a ...interface{}) {
-	t := time.Now()
+	l.printf(0, format, a)
+}
+func (l *ALogger) Printfl(lvl Level, format string, a ...interface{}) {
+	l.printf(lvl, format, a)
+}
+func (l *ALogger) Print(a ...interface{}) {
+	l.print(0, a)
+}
+func (l *ALogger) Printl(lvl Level, a ...interface{}) {
+	l.print(lvl, a)
+}
+func (l *ALogger) Printj(addPrefix string, a interface{}) {
+	l.printj(0, addPrefix, a)
+}
+func (l *ALogger) Printjl(lvl Level, addPrefix string, a interface{}) {
+	l.printj(lvl, addPrefix, a)
+}
 
-	flagKeyword := false
-	var aIdx int = 0
-	var aLen = len(a)
+// Printw prints a message followed by typed key/value fields: `msg key1=val1 key2=val2`
+func (l *ALogger) Printw(msg string, fields ...Field) {
+	l.printw(0, msg, fields)
+}
+func (l *ALogger) Printwl(lvl Level, msg string, fields ...Field) {
+	l.printw(lvl, msg, fields)
+}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+// =====================================================================================================================
+// A LOGGER / WRITE PATH
+// =====================================================================================================================
+// Every print method comes down to one of print, printf, printj and printw below.
+// lvl == 0 is an unleveled call, which is not filtered by the level.
 
-	if !l.bufUseBuffer { // if buffer write is not used, reset buffer each time.
-		l.buf = l.buf[:0]
+func (l *ALogger) print(lvl Level, a []interface{}) {
+	if lvl != 0 && (l.lvl|l.outLevels())&lvl == 0 {
+		return
 	}
+	t := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	l.formatHeader(&l.buf, t)
-
-	for _, c := range format {
-		if flagKeyword == false {
-			if c == '%' {
-				flagKeyword = true
-			} else {
-				l.buf = append(l.buf, byte(c))
-			}
-		} else {
-			// flagKeyword == true
-			if c == '%' {
-				l.buf = append(l.buf, '%')
-				flagKeyword = false
-				continue
-			}
-			if aIdx >= aLen {
-				flagKeyword = false
-				continue
-			}
-			switch c {
-			case 'd':
-				if v, ok := a[aIdx].(int); ok {
-					itoa(&l.buf, v, 0)
-				} else {
-					l.buf = append(l.buf, unsuppType...)
-				}
-				aIdx++
-			case 's':
-				if v, ok := a[aIdx].(string); ok {
-					l.buf = append(l.buf, []byte(v)...)
-				} else {
-					l.buf = append(l.buf, unsuppType...)
-				}
-				aIdx++
-			case 'f':
-				switch a[aIdx].(type) {
-				case float64:
-					if v, ok := a[aIdx].(float64); ok {
-						ftoa(&l.buf, v, 2)
-					} else {
-						l.buf = append(l.buf, unsuppType...)
-					}
-				case float32:
-					if v, ok := a[aIdx].(float32); ok {
-						ftoa(&l.buf, float64(v), 2)
-					} else {
-						l.buf = append(l.buf, unsuppType...)
-					}
-				}
-				aIdx++
-			case 't':
-				if v, ok := a[aIdx].(bool); ok {
-					if v {
-						l.buf = append(l.buf, []byte("true")...)
-					} else {
-						l.buf = append(l.buf, []byte("false")...)
-					}
-				} else {
-					l.buf = append(l.buf, unsuppType...)
-				}
-				aIdx++
-			}
-			flagKeyword = false
-		}
-	}
-	curBufSize := len(l.buf)
-	if curBufSize == 0 || l.buf[curBufSize-1] != '\n' {
-		l.buf = append(l.buf, '\n')
-	}
-	if curBufSize > l.bufSize {
-		l.out.Write(l.buf)
-		l.buf = l.buf[:0]
-	}
+	l.msg = l.msg[:0]
+	appendPrint(&l.msg, a)
+	l.output(lvl, t, l.callerPC(), l.msg, nil)
 }
-func (l *ALogger) Printfl(lvl Level, format string, a ...interface{}) {
-	if l.lvl&lvl == 0 {
+func (l *ALogger) printf(lvl Level, format string, a []interface{}) {
+	if lvl != 0 && (l.lvl|l.outLevels())&lvl == 0 {
 		return
 	}
-
 	t := time.Now()
-
-	flagKeyword := false
-	var aIdx int = 0
-	var aLen = len(a)
-
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if !l.bufUseBuffer { // if buffer write is not used, reset buffer each time.
-		l.buf = l.buf[:0]
-	}
-
-	l.formatHeader(&l.buf, t)
-
-	for _, c := range format {
-		if flagKeyword == false {
-			if c == '%' {
-				flagKeyword = true
-			} else {
-				l.buf = append(l.buf, byte(c))
-			}
-		} else {
-			// flagKeyword == true
-			if c == '%' {
-				l.buf = append(l.buf, '%')
-				flagKeyword = false
-				continue
-			}
-			if aIdx >= aLen {
-				flagKeyword = false
-				continue
-			}
-			switch c {
-			case 'd':
-				if v, ok := a[aIdx].(int); ok {
-					itoa(&l.buf, v, 0)
-				} else {
-					l.buf = append(l.buf, unsuppType...)
-				}
-				aIdx++
-			case 's':
-				if v, ok := a[aIdx].(string); ok {
-					l.buf = append(l.buf, []byte(v)...)
-				} else {
-					l.buf = append(l.buf, unsuppType...)
-				}
-				aIdx++
-			case 'f':
-				switch a[aIdx].(type) {
-				case float64:
-					if v, ok := a[aIdx].(float64); ok {
-						ftoa(&l.buf, v, 2)
-					} else {
-						l.buf = append(l.buf, unsuppType...)
-					}
-				case float32:
-					if v, ok := a[aIdx].(float32); ok {
-						ftoa(&l.buf, float64(v), 2)
-					} else {
-						l.buf = append(l.buf, unsuppType...)
-					}
-				}
-				aIdx++
-			case 't':
-				if v, ok := a[aIdx].(bool); ok {
-					if v {
-						l.buf = append(l.buf, []byte("true")...)
-					} else {
-						l.buf = append(l.buf, []byte("false")...)
-					}
-				} else {
-					l.buf = append(l.buf, unsuppType...)
-				}
-				aIdx++
-			}
-			flagKeyword = false
-		}
-	}
-	curBufSize := len(l.buf)
-	if curBufSize == 0 || l.buf[curBufSize-1] != '\n' {
-		l.buf = append(l.buf, '\n')
-	}
-	if curBufSize > l.bufSize {
-		l.out.Write(l.buf)
-		l.buf = l.buf[:0]
-	}
+	l.msg = l.msg[:0]
+	appendPrintf(&l.msg, format, a, l.fprec)
+	l.output(lvl, t, l.callerPC(), l.msg, nil)
 }
-
-func (l *ALogger) Print(a ...interface{}) {
+func (l *ALogger) printj(lvl Level, addPrefix string, a interface{}) {
+	if lvl != 0 && (l.lvl|l.outLevels())&lvl == 0 {
+		return
+	}
 	t := time.Now()
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if !l.bufUseBuffer { // if buffer write is not used, reset buffer each time.
-		l.buf = l.buf[:0]
-	}
-
-	l.formatHeader(&l.buf, t)
-	// -- START
-
-	for _, v := range a {
-		switch v.(type) {
-		case string:
-			l.buf = append(l.buf, []byte(v.(string))...)
-		case int:
-			l.buf = strconv.AppendInt(l.buf, int64(v.(int)), 10)
-		case int8:
-			l.buf = strconv.AppendInt(l.buf, int64(v.(int8)), 10)
-		case int16:
-			l.buf = strconv.AppendInt(l.buf, int64(v.(int16)), 10)
-		case int32:
-			l.buf = strconv.AppendInt(l.buf, int64(v.(int32)), 10)
-		case int64:
-			l.buf = strconv.AppendInt(l.buf, v.(int64), 10)
-		case bool:
-			l.buf = strconv.AppendBool(l.buf, v.(bool))
-		case uint:
-			l.buf = strconv.AppendUint(l.buf, uint64(v.(uint)), 10)
-		case uint8:
-			l.buf = strconv.AppendUint(l.buf, uint64(v.(uint8)), 10)
-		case uint16:
-			l.buf = strconv.AppendUint(l.buf, uint64(v.(uint16)), 10)
-		case uint32:
-			l.buf = strconv.AppendUint(l.buf, uint64(v.(uint32)), 10)
-		case uint64:
-			l.buf = strconv.AppendUint(l.buf, v.(uint64), 10)
-		case float32:
-			l.buf = strconv.AppendFloat(l.buf, float64(v.(float32)), 'f', -1, 32)
-		case float64:
-			l.buf = strconv.AppendFloat(l.buf, v.(float64), 'f', -1, 64)
-		case []byte:
-			l.buf = append(l.buf, v.([]byte)...)
-		default:
-			l.buf = append(l.buf, unsuppType...)
-		}
-	}
 
-	// -- END
-	curBufSize := len(l.buf)
-	if curBufSize == 0 || l.buf[curBufSize-1] != '\n' {
-		l.buf = append(l.buf, '\n')
-	}
-	if curBufSize > l.bufSize {
-		l.out.Write(l.buf)
-		l.buf = l.buf[:0]
-	}
+	l.msg = append(l.msg[:0], addPrefix...)
+	l.encodeJSON(a)
+	l.output(lvl, t, l.callerPC(), l.msg, []Field{{Key: "data", Type: FieldJSON, Iface: &l.buf2}})
 }
-
-func (l *ALogger) Printl(lvl Level, a ...interface{}) {
-	if l.lvl&lvl == 0 {
+func (l *ALogger) printw(lvl Level, msg string, fields []Field) {
+	if lvl != 0 && (l.lvl|l.outLevels())&lvl == 0 {
 		return
 	}
 	t := time.Now()
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if !l.bufUseBuffer { // if buffer write is not used, reset buffer each time.
-		l.buf = l.buf[:0]
-	}
-
-	l.formatHeader(&l.buf, t)
-	// -- START
-
-	for _, v := range a {
-		switch v.(type) {
-		case string:
-			l.buf = append(l.buf, []byte(v.(string))...)
-		case int:
-			l.buf = strconv.AppendInt(l.buf, int64(v.(int)), 10)
-		case int8:
-			l.buf = strconv.AppendInt(l.buf, int64(v.(int8)), 10)
-		case int16:
-			l.buf = strconv.AppendInt(l.buf, int64(v.(int16)), 10)
-		case int32:
-			l.buf = strconv.AppendInt(l.buf, int64(v.(int32)), 10)
-		case int64:
-			l.buf = strconv.AppendInt(l.buf, v.(int64), 10)
-		case bool:
-			l.buf = strconv.AppendBool(l.buf, v.(bool))
-		case uint:
-			l.buf = strconv.AppendUint(l.buf, uint64(v.(uint)), 10)
-		case uint8:
-			l.buf = strconv.AppendUint(l.buf, uint64(v.(uint8)), 10)
-		case uint16:
-			l.buf = strconv.AppendUint(l.buf, uint64(v.(uint16)), 10)
-		case uint32:
-			l.buf = strconv.AppendUint(l.buf, uint64(v.(uint32)), 10)
-		case uint64:
-			l.buf = strconv.AppendUint(l.buf, v.(uint64), 10)
-		case float32:
-			l.buf = strconv.AppendFloat(l.buf, float64(v.(float32)), 'f', -1, 32)
-		case float64:
-			l.buf = strconv.AppendFloat(l.buf, v.(float64), 'f', -1, 64)
-		case []byte:
-			l.buf = append(l.buf, v.([]byte)...)
-		default:
-			l.buf = append(l.buf, unsuppType...)
-		}
-	}
 
-	// -- END
-	curBufSize := len(l.buf)
-	if curBufSize == 0 || l.buf[curBufSize-1] != '\n' {
-		l.buf = append(l.buf, '\n')
-	}
-	if curBufSize > l.bufSize {
-		l.out.Write(l.buf)
-		l.buf = l.buf[:0]
-	}
+	l.msg = append(l.msg[:0], msg...)
+	l.output(lvl, t, l.callerPC(), l.msg, fields)
 }
 
-func (l *ALogger) Printj(addPrefix string, a interface{}) {
-	t := time.Now()
+// jsonMsg returns addPrefix followed by `a` in JSON, as written by printj in text.
+func (l *ALogger) jsonMsg(addPrefix string, a interface{}) string {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if !l.bufUseBuffer { // if buffer write is not used, reset buffer each time.
-		l.buf = l.buf[:0]
-	}
+	l.encodeJSON(a)
+	return addPrefix + string(l.buf2)
+}
 
-	l.formatHeader(&l.buf, t)
-	if addPrefix != "" {
-		l.buf = append(l.buf, []byte(addPrefix)...)
-	}
-	if a == nil {
-		l.buf = append(l.buf, []byte("{}")...)
-	} else {
-		l.buf2 = l.buf2[:0]
-		if l.buf2 == nil { // *json.Encode hasn't been initialized until needed.
+// encodeJSON encodes `a` into l.buf2 without a trailing newline.
+// When `a` is nil or can't be encoded, `{}` will be used.
+func (l *ALogger) encodeJSON(a interface{}) {
+	l.buf2 = l.buf2[:0]
+	if a != nil {
+		if l.jsonEnc == nil { // *json.Encode hasn't been initialized until needed.
 			l.jsonEnc = json.NewEncoder(&l.buf2)
 		}
-		if l.jsonEnc.Encode(a) != nil {
-			l.buf = append(l.buf, []byte("{}")...)
-		} else {
-			l.buf = append(l.buf, l.buf2...)
+		if l.jsonEnc.Encode(a) == nil {
+			if n := len(l.buf2); n > 0 && l.buf2[n-1] == '\n' {
+				l.buf2 = l.buf2[:n-1]
+			}
+			return
 		}
+		l.buf2 = l.buf2[:0]
 	}
-
-	curBufSize := len(l.buf)
-	if curBufSize == 0 || l.buf[curBufSize-1] != '\n' {
-		l.buf = append(l.buf, '\n')
-	}
-	if curBufSize > l.bufSize {
-		l.out.Write(l.buf)
-		l.buf = l.buf[:0]
-	}
+	l.buf2 = append(l.buf2, "{}"...)
 }
 
-func (l *ALogger) Printjl(lvl Level, addPrefix string, a interface{}) {
-	if l.lvl&lvl == 0 {
-		return
+// output hands a single entry to additional outputs of its level, then to the logger's own output.
+// Caller must hold l.mu.
+func (l *ALogger) output(lvl Level, t time.Time, pc uintptr, msg []byte, fields []Field) {
+	c := l.callerOf(pc)
+	if l.stackLvl != 0 && lvl >= l.stackLvl {
+		fields = l.appendStack(fields)
 	}
-	t := time.Now()
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	if !l.bufUseBuffer { // if buffer write is not used, reset buffer each time.
-		l.buf = l.buf[:0]
-	}
-
-	l.formatHeader(&l.buf, t)
-	if addPrefix != "" {
-		l.buf = append(l.buf, []byte(addPrefix)...)
-	}
-	if a == nil {
-		l.buf = append(l.buf, []byte("{}")...)
-	} else {
-		l.buf2 = l.buf2[:0]
-		if l.buf2 == nil { // *json.Encode hasn't been initialized until needed.
-			l.jsonEnc = json.NewEncoder(&l.buf2)
-		}
-		if l.jsonEnc.Encode(a) != nil {
-			l.buf = append(l.buf, []byte("{}")...)
-		} else {
-			l.buf = append(l.buf, l.buf2...)
+	for _, o := range l.outputs {
+		if lvl == 0 || o.lvl&lvl != 0 {
+			o.output(lvl, t, c, l.prefix, msg, l.preset, fields)
 		}
 	}
-
-	curBufSize := len(l.buf)
-	if curBufSize == 0 || l.buf[curBufSize-1] != '\n' {
-		l.buf = append(l.buf, '\n')
+	if lvl != 0 && l.lvl&lvl == 0 { // only for additional outputs
+		return
 	}
-	if curBufSize > l.bufSize {
-		l.out.Write(l.buf)
-		l.buf = l.buf[:0]
+	l.sink.output(lvl, t, c, l.prefix, msg, l.preset, fields)
+}
+
+// timeFlag removes time flags for a zero time such as one from slog.Record
+func timeFlag(flag Format, t time.Time) Format {
+	if t.IsZero() {
+		return flag &^ (F_DATE | F_MMDD | F_TIME | F_MICROSEC)
 	}
+	return flag
 }
 
-// just in case when io.Writer has a .Close() method like a file
+// just in case when io.Writer has a .Close() method like a file.
+// Additional outputs are flushed and closed as well; the first error is returned.
 func (l *ALogger) Close() error {
+	l.SetFlushInterval(0)
 	l.Flush()
+	l.SetAsync(0, OVERFLOW_BLOCK)
+
+	var err error
+	l.mu.Lock()
+	for _, o := range l.outputs {
+		o.stopAsync()
+		if c, ok := o.out.(io.Closer); ok && c != nil {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	l.mu.Unlock()
+
 	if c, ok := l.out.(io.Closer); ok && c != nil {
-		return c.Close()
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
 	}
-	return nil
+	return err
 }
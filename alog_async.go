@@ -0,0 +1,165 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"io"
+	"sync"
+)
+
+// =====================================================================================================================
+// A LOGGER / ASYNC
+// =====================================================================================================================
+
+// Overflow is a policy of async mode when its queue is full
+type Overflow uint8
+
+const (
+	OVERFLOW_BLOCK       Overflow = iota // wait until the queue has a room
+	OVERFLOW_DROP_NEWEST                 // drop the entry being written
+	OVERFLOW_DROP_OLDEST                 // drop the oldest entry in the queue
+)
+
+// SetAsync hands formatted entries to a background goroutine through a queue of `size` entries
+// instead of writing them to the output in the caller. When the queue is full, `policy` decides
+// what to do. Flush and Close wait until the queue is drained.
+// With size <= 0, the queue is drained and async mode is turned off.
+func (l *ALogger) SetAsync(size int, policy Overflow) {
+	l.mu.Lock()
+	l.setAsync(size, policy)
+	l.mu.Unlock()
+}
+
+// Dropped returns number of entries dropped by async mode's overflow policy
+func (l *ALogger) Dropped() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.droppedCount()
+}
+
+// asyncQueue is a ring buffer of entries written to `out` by a goroutine.
+// Slots keep their memory, so an entry is copied without allocation once slots have grown.
+type asyncQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // broadcast whenever the queue changes
+	out     io.Writer
+	write   func(w io.Writer, lvl Level, p []byte) // core.writeTo
+	slots   [][]byte
+	lvls    []Level // level of each slot
+	head    int     // index of the oldest entry
+	n       int     // number of entries in the queue
+	policy  Overflow
+	dropped uint64
+	busy    bool // an entry is being written
+	closed  bool
+	done    chan struct{}
+}
+
+func newAsyncQueue(out io.Writer, size int, policy Overflow, write func(w io.Writer, lvl Level, p []byte)) *asyncQueue {
+	q := &asyncQueue{
+		out:    out,
+		write:  write,
+		slots:  make([][]byte, size),
+		lvls:   make([]Level, size),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+// push copies p into the queue
+func (q *asyncQueue) push(lvl Level, p []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.n == len(q.slots) {
+		switch q.policy {
+		case OVERFLOW_DROP_NEWEST:
+			q.dropped++
+			return
+		case OVERFLOW_DROP_OLDEST:
+			q.head = (q.head + 1) % len(q.slots)
+			q.n--
+			q.dropped++
+		default:
+			for q.n == len(q.slots) && !q.closed {
+				q.cond.Wait()
+			}
+		}
+	}
+	if q.closed {
+		return
+	}
+	i := (q.head + q.n) % len(q.slots)
+	q.slots[i] = append(q.slots[i][:0], p...)
+	q.lvls[i] = lvl
+	q.n++
+	q.cond.Broadcast()
+}
+
+// run writes entries until the queue is stopped and drained.
+// A taken slot is swapped with `spare` so the write happens without the lock.
+func (q *asyncQueue) run() {
+	var spare []byte
+	q.mu.Lock()
+	for {
+		for q.n == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.n == 0 { // closed and drained
+			break
+		}
+		spare, q.slots[q.head] = q.slots[q.head], spare[:0]
+		lvl := q.lvls[q.head]
+		q.head = (q.head + 1) % len(q.slots)
+		q.n--
+		q.busy = true
+		out := q.out
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		q.write(out, lvl, spare)
+
+		q.mu.Lock()
+		q.busy = false
+		q.cond.Broadcast()
+	}
+	q.mu.Unlock()
+	close(q.done)
+}
+
+// drain waits until every entry in the queue is written
+func (q *asyncQueue) drain() {
+	q.mu.Lock()
+	for q.n > 0 || q.busy {
+		q.cond.Wait()
+	}
+	q.mu.Unlock()
+}
+
+// setOutput changes the writer after entries queued for the previous one are written
+func (q *asyncQueue) setOutput(out io.Writer) {
+	q.drain()
+	q.mu.Lock()
+	q.out = out
+	q.mu.Unlock()
+}
+
+// stop drains the queue, stops the goroutine, and returns the dropped count
+func (q *asyncQueue) stop() uint64 {
+	q.drain()
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	<-q.done
+	return q.dropped
+}
+
+func (q *asyncQueue) droppedCount() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
@@ -0,0 +1,202 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// =====================================================================================================================
+// CALLER
+// =====================================================================================================================
+
+// F_CALLER, F_LONGFILE and F_FUNC flags
+const callerFlags = F_CALLER | F_LONGFILE | F_FUNC
+
+// callerDepth is frames from runtime.Callers in callerPC to the caller of a print method:
+// runtime.Callers, callerPC, print/printf/printj/printw/printCtx, and a method such as Info.
+const callerDepth = 4
+
+// Caller is the source location of a log call, set in Header with F_CALLER, F_LONGFILE or F_FUNC.
+type Caller struct {
+	File string // full path
+	Line int
+	Func string // function name with its package such as `main.run` or `alog.(*ALogger).Info`
+
+	short string // `file.go:12`
+	long  string // `/path/to/file.go:12`
+}
+
+// SetCallerSkip sets number of extra frames to skip to find the caller for F_CALLER, F_LONGFILE
+// and F_FUNC. Use it when the logger is called through a wrapper; eg. 1 for a function calling l.Info.
+func (l *ALogger) SetCallerSkip(skip int) {
+	l.mu.Lock()
+	l.callerSkip = skip
+	l.mu.Unlock()
+}
+
+// callerPC returns the pc of the caller of a print method when any output needs it, or 0.
+// It must be called directly from print, printf, printj, printw or printCtx. Caller must hold l.mu.
+func (l *ALogger) callerPC() uintptr {
+	if (l.flag|l.outFlag)&callerFlags == 0 {
+		return 0
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(callerDepth+l.callerSkip, pcs[:]) < 1 {
+		return 0
+	}
+	return pcs[0]
+}
+
+// callerOf resolves pc. Resolved ones are cached as a program has a limited number of call sites.
+// Caller must hold l.mu.
+func (l *ALogger) callerOf(pc uintptr) Caller {
+	if pc == 0 {
+		return Caller{}
+	}
+	if c, ok := l.callers[pc]; ok {
+		return c
+	}
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	c := Caller{File: f.File, Line: f.Line, Func: f.Function}
+	if i := strings.LastIndexByte(c.Func, '/'); i >= 0 {
+		c.Func = c.Func[i+1:]
+	}
+	line := ":" + strconv.Itoa(f.Line)
+	c.long = c.File + line
+	c.short = c.File[strings.LastIndexByte(c.File, '/')+1:] + line
+
+	if l.callers == nil {
+		l.callers = make(map[uintptr]Caller)
+	}
+	l.callers[pc] = c
+	return c
+}
+
+// loc returns `file:line` by the flag; empty when there's no file.
+func (c *Caller) loc(flag Format) string {
+	if c.File == "" {
+		return ""
+	}
+	if flag&F_LONGFILE != 0 {
+		return c.long
+	}
+	if flag&F_CALLER != 0 {
+		return c.short
+	}
+	return ""
+}
+
+// appendCallerText appends `file.go:12 pkg.func: ` by the flag
+func appendCallerText(dst *[]byte, h *Header) {
+	loc := h.Caller.loc(h.Flag)
+	fn := ""
+	if h.Flag&F_FUNC != 0 {
+		fn = h.Caller.Func
+	}
+	if loc == "" && fn == "" {
+		return
+	}
+	*dst = append(*dst, loc...)
+	if loc != "" && fn != "" {
+		*dst = append(*dst, ' ')
+	}
+	*dst = append(*dst, fn...)
+	*dst = append(*dst, ':', ' ')
+}
+
+// =====================================================================================================================
+// CALLER / STACK
+// =====================================================================================================================
+
+// maxStackDepth is max number of frames captured for a stack trace
+const maxStackDepth = 64
+
+// Stack is a stack trace from the caller of a log method. See SetStackLevel.
+type Stack []Caller
+
+// pkgPrefix is the prefix of function names in this package such as `github.com/gonyyi/alog.`
+var pkgPrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	i := strings.LastIndexByte(name, '/') + 1
+	return name[:i+strings.IndexByte(name[i:], '.')+1]
+}()
+
+// SetStackLevel adds a stack trace to entries of lvl or above; eg. ERROR for ERROR, FATAL and PANIC.
+// When lvl has more than one level such as ERROR|FATAL, the lowest one is used.
+// It's written in lines after the entry in text, and as a "stack" array in JSON. When lvl is 0 (default),
+// stack traces are not added. Frames of alog (and log/slog) are trimmed, and SetCallerSkip applies too.
+func (l *ALogger) SetStackLevel(lvl Level) {
+	l.mu.Lock()
+	l.stackLvl = lvl & -lvl // lowest level
+	l.mu.Unlock()
+}
+
+// appendStack returns fields with a "stack" field of the current stack. Caller must hold l.mu.
+func (l *ALogger) appendStack(fields []Field) []Field {
+	if l.stackPCs == nil {
+		l.stackPCs = make([]uintptr, maxStackDepth)
+	}
+	frames := runtime.CallersFrames(l.stackPCs[:runtime.Callers(2, l.stackPCs)])
+	l.stack = l.stack[:0]
+	inUser, skip := false, l.callerSkip
+	for more := true; more; {
+		var f runtime.Frame
+		f, more = frames.Next()
+		if !inUser && (strings.HasPrefix(f.Function, pkgPrefix) || strings.HasPrefix(f.Function, "log/slog.")) {
+			continue
+		}
+		inUser = true
+		if skip > 0 {
+			skip--
+			continue
+		}
+		fn := f.Function
+		if i := strings.LastIndexByte(fn, '/'); i >= 0 {
+			fn = fn[i+1:]
+		}
+		l.stack = append(l.stack, Caller{File: f.File, Line: f.Line, Func: fn})
+	}
+
+	l.stackFields = append(l.stackFields[:0], fields...)
+	return append(l.stackFields, Field{Key: "stack", Type: FieldStack, Iface: &l.stack})
+}
+
+// appendStackText appends frames in lines: `\n\tpkg.func\n\t\t/path/to/file.go:12`
+func appendStackText(dst *[]byte, f Field) {
+	s, _ := f.Iface.(*Stack)
+	if s == nil {
+		return
+	}
+	for _, c := range *s {
+		*dst = append(*dst, "\n\t"...)
+		*dst = append(*dst, c.Func...)
+		*dst = append(*dst, "\n\t\t"...)
+		*dst = append(*dst, c.File...)
+		*dst = append(*dst, ':')
+		itoa(dst, c.Line, 0)
+	}
+}
+
+// appendStackJSON appends frames as an array: `[{"func":"pkg.func","file":"/path/to/file.go","line":12}]`
+func appendStackJSON(dst *[]byte, f Field) {
+	*dst = append(*dst, '[')
+	if s, _ := f.Iface.(*Stack); s != nil {
+		for i, c := range *s {
+			if i > 0 {
+				*dst = append(*dst, ',')
+			}
+			*dst = append(*dst, `{"func":`...)
+			appendJSONString(dst, c.Func)
+			*dst = append(*dst, `,"file":`...)
+			appendJSONString(dst, c.File)
+			*dst = append(*dst, `,"line":`...)
+			itoa(dst, c.Line, 0)
+			*dst = append(*dst, '}')
+		}
+	}
+	*dst = append(*dst, ']')
+}
@@ -0,0 +1,196 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"context"
+	"time"
+)
+
+// =====================================================================================================================
+// CONTEXT
+// =====================================================================================================================
+
+type ctxKey uint8
+
+const (
+	ctxKeyLogger ctxKey = iota
+	ctxKeyFields
+)
+
+// ContextHook appends fields taken from a context such as trace and span IDs to dst.
+type ContextHook func(ctx context.Context, dst []Field) []Field
+
+// NewContext returns a context carrying the logger. See FromContext.
+func NewContext(ctx context.Context, l *ALogger) context.Context {
+	return context.WithValue(ctx, ctxKeyLogger, l)
+}
+
+// ContextWith returns a context carrying fields in addition to ones already in ctx.
+// They are added to entries written by `*Ctx` methods and loggers from FromContext.
+func ContextWith(ctx context.Context, fields ...Field) context.Context {
+	prev, _ := ctx.Value(ctxKeyFields).(*ctxFields)
+	fs := &ctxFields{parent: prev}
+	if prev != nil {
+		fs.fields = make([]Field, 0, len(prev.fields)+len(fields))
+		fs.fields = append(fs.fields, prev.fields...)
+	}
+	fs.fields = append(fs.fields, fields...)
+	return context.WithValue(ctx, ctxKeyFields, fs)
+}
+
+// ctxFields is fields stored by ContextWith; fields include ones of the parent.
+type ctxFields struct {
+	fields []Field
+	parent *ctxFields
+}
+
+// since returns fields added after base when fs is derived from base, or all fields otherwise.
+func (fs *ctxFields) since(base *ctxFields) []Field {
+	if fs == nil {
+		return nil
+	}
+	for p := fs; p != nil; p = p.parent {
+		if p == base {
+			return fs.fields[len(base.fields):]
+		}
+	}
+	return fs.fields
+}
+
+// ctxBase is the context whose fields are added to a logger by FromContext
+type ctxBase struct {
+	ctx    context.Context
+	fields *ctxFields
+	hooked []Field // fields from context hooks
+}
+
+// FromContext returns a logger in ctx (or the standard logger if none), with fields of ctx
+// and its context hooks added as With does. `*Ctx` methods of the returned logger (and its children)
+// don't add them again: for the same ctx, nothing is added, and for a context derived from ctx,
+// only fields added after ctx and hook fields different from ctx's are added.
+func FromContext(ctx context.Context) *ALogger {
+	l := loggerOf(ctx)
+	l.mu.Lock()
+	hooks := l.ctxHooks
+	l.mu.Unlock()
+
+	if ctx == nil {
+		return l
+	}
+	base := &ctxBase{ctx: ctx}
+	base.fields, _ = ctx.Value(ctxKeyFields).(*ctxFields)
+	for _, h := range hooks {
+		base.hooked = h(ctx, base.hooked)
+	}
+	if base.fields == nil && len(base.hooked) == 0 {
+		return l
+	}
+	fs := make([]Field, 0, len(base.fields.since(nil))+len(base.hooked))
+	fs = append(fs, base.fields.since(nil)...)
+	c := l.With(append(fs, base.hooked...)...)
+	c.ctxBase = base
+	return c
+}
+
+// loggerOf returns a logger in ctx, or the standard logger
+func loggerOf(ctx context.Context) *ALogger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKeyLogger).(*ALogger); ok && l != nil {
+			return l
+		}
+	}
+	return std
+}
+
+// appendContextFields appends fields of ctx and ones from hooks. When base is not nil,
+// ones already added by FromContext are skipped.
+func appendContextFields(dst []Field, ctx context.Context, hooks []ContextHook, base *ctxBase) []Field {
+	if ctx == nil {
+		return dst
+	}
+	if base != nil && base.ctx == ctx {
+		return dst
+	}
+	var baseFields *ctxFields
+	if base != nil {
+		baseFields = base.fields
+	}
+	fs, _ := ctx.Value(ctxKeyFields).(*ctxFields)
+	dst = append(dst, fs.since(baseFields)...)
+	start := len(dst)
+	for _, h := range hooks {
+		dst = h(ctx, dst)
+	}
+	if base == nil {
+		return dst
+	}
+	// skip hook fields same as ones from the base
+	n := start
+	for i := start; i < len(dst); i++ {
+		if !base.hasHooked(dst[i]) {
+			dst[n] = dst[i]
+			n++
+		}
+	}
+	return dst[:n]
+}
+
+// hasHooked returns true when f is one of hook fields of the base. Fields with Iface are never same.
+func (b *ctxBase) hasHooked(f Field) bool {
+	if f.Iface != nil {
+		return false
+	}
+	for _, h := range b.hooked {
+		if h.Iface == nil && h.Key == f.Key && h.Type == f.Type && h.Int == f.Int && h.Float == f.Float && h.Str == f.Str {
+			return true
+		}
+	}
+	return false
+}
+
+// AddContextHook registers a hook which adds fields from a context to entries of `*Ctx` methods
+// and loggers from FromContext. As a hook can be called while the logger is locked,
+// it must not log with the same logger.
+func (l *ALogger) AddContextHook(fn ContextHook) {
+	l.mu.Lock()
+	l.ctxHooks = append(l.ctxHooks, fn)
+	l.mu.Unlock()
+}
+
+func (l *ALogger) DebugCtx(ctx context.Context, msg string, fields ...Field) {
+	l.printCtx(ctx, DEBUG, msg, fields)
+}
+func (l *ALogger) InfoCtx(ctx context.Context, msg string, fields ...Field) {
+	l.printCtx(ctx, INFO, msg, fields)
+}
+func (l *ALogger) WarnCtx(ctx context.Context, msg string, fields ...Field) {
+	l.printCtx(ctx, WARN, msg, fields)
+}
+func (l *ALogger) ErrorCtx(ctx context.Context, msg string, fields ...Field) {
+	l.printCtx(ctx, ERROR, msg, fields)
+}
+func (l *ALogger) FatalCtx(ctx context.Context, msg string, fields ...Field) {
+	l.printCtx(ctx, FATAL, msg, fields)
+	l.exit()
+}
+func (l *ALogger) PanicCtx(ctx context.Context, msg string, fields ...Field) {
+	l.printCtx(ctx, PANIC, msg, fields)
+	l.panic(msg)
+}
+
+// printCtx is printw with fields of ctx and context hooks before fields.
+// For a logger from FromContext, ones already added are skipped.
+func (l *ALogger) printCtx(ctx context.Context, lvl Level, msg string, fields []Field) {
+	if lvl != 0 && (l.lvl|l.outLevels())&lvl == 0 {
+		return
+	}
+	t := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ctxFields = appendContextFields(l.ctxFields[:0], ctx, l.ctxHooks, l.ctxBase)
+	l.ctxFields = append(l.ctxFields, fields...)
+	l.msg = append(l.msg[:0], msg...)
+	l.output(lvl, t, l.callerPC(), l.msg, l.ctxFields)
+}
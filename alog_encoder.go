@@ -0,0 +1,268 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import "time"
+
+// =====================================================================================================================
+// ENCODER
+// =====================================================================================================================
+
+// Encoder formats an entry into the logger's buffer. For each entry, ALogger calls
+// Begin, Header, Message, Field (once per field), and End in that order while holding its lock.
+// Encoder should only append to dst. Field's output shouldn't depend on what's already in dst,
+// as fields of a child logger (see With) are rendered once and reused.
+type Encoder interface {
+	Begin(dst *[]byte)
+	Header(dst *[]byte, h *Header)
+	Message(dst *[]byte, msg []byte)
+	Field(dst *[]byte, f Field)
+	End(dst *[]byte)
+}
+
+// Header has information written before a message.
+// Level is 0 for an unleveled call such as Print, and Prefix is set regardless of F_PREFIX.
+type Header struct {
+	Flag   Format
+	Time   time.Time
+	Level  Level
+	Prefix []byte
+	Caller Caller // set with F_CALLER, F_LONGFILE or F_FUNC
+}
+
+// encoder returns an encoder set by SetEncoder, or a builtin one selected by the flag
+func (l *ALogger) encoder() Encoder {
+	return encoderOf(l.enc, l.flag)
+}
+
+// encoderOf returns enc if not nil, or a builtin encoder selected by the flag
+func encoderOf(enc Encoder, flag Format) Encoder {
+	if enc != nil {
+		return enc
+	}
+	if flag&F_JSON != 0 {
+		return JSONEncoder{}
+	}
+	if flag&F_LOGFMT != 0 {
+		return LogfmtEncoder{}
+	}
+	return TextEncoder{}
+}
+
+// encodeEntry appends an entry using enc. pre is fields of a child logger already rendered by enc.
+func encodeEntry(dst *[]byte, enc Encoder, h *Header, msg []byte, pre []byte, fields []Field) {
+	enc.Begin(dst)
+	enc.Header(dst, h)
+	enc.Message(dst, msg)
+	*dst = append(*dst, pre...)
+	for i := 0; i < len(fields); i++ {
+		enc.Field(dst, fields[i])
+	}
+	enc.End(dst)
+}
+
+// =====================================================================================================================
+// ENCODER / TEXT
+// =====================================================================================================================
+
+// TextEncoder is the default encoder: `01/02 15:04:05 [INF] prefix message key=value`
+type TextEncoder struct{}
+
+func (TextEncoder) Begin(dst *[]byte) {}
+
+// Header is modified from builtin logger
+func (TextEncoder) Header(dst *[]byte, h *Header) {
+	if h.Flag&(F_DATE|F_MMDD|F_TIME|F_MICROSEC) != 0 {
+		t := h.Time
+		if h.Flag&F_UTC != 0 {
+			t = t.UTC()
+		}
+		if h.Flag&(F_DATE|F_MMDD) != 0 {
+			year, month, day := t.Date()
+			if h.Flag&F_DATE != 0 {
+				itoa(dst, year, 4)
+				*dst = append(*dst, '/')
+			}
+			itoa(dst, int(month), 2)
+			*dst = append(*dst, '/')
+			itoa(dst, day, 2)
+			*dst = append(*dst, ' ')
+		}
+		if h.Flag&(F_TIME|F_MICROSEC) != 0 {
+			hour, min, sec := t.Clock()
+			itoa(dst, hour, 2)
+			*dst = append(*dst, ':')
+			itoa(dst, min, 2)
+			*dst = append(*dst, ':')
+			itoa(dst, sec, 2)
+			if h.Flag&F_MICROSEC != 0 {
+				*dst = append(*dst, '.')
+				itoa(dst, t.Nanosecond()/1e3, 6)
+			}
+			*dst = append(*dst, ' ')
+		}
+	}
+	if h.Flag&(F_LEVEL|F_LEVEL_FULL) != 0 && h.Level != 0 {
+		if tag := h.Level.tag(h.Flag&F_LEVEL_FULL != 0); tag != "" {
+			*dst = append(*dst, tag...)
+			*dst = append(*dst, ' ')
+		}
+	}
+	if h.Flag&F_PREFIX != 0 {
+		*dst = append(*dst, h.Prefix...)
+	}
+	if h.Flag&callerFlags != 0 {
+		appendCallerText(dst, h)
+	}
+}
+
+func (TextEncoder) Message(dst *[]byte, msg []byte) {
+	*dst = append(*dst, msg...)
+}
+
+// Field appends ` key=value`. FieldJSON is appended as is without a key,
+// which is how Printj has been printed. FieldStack is appended in lines.
+func (TextEncoder) Field(dst *[]byte, f Field) {
+	switch f.Type {
+	case FieldJSON:
+		*dst = append(*dst, marshalJSON(f.Iface)...)
+		return
+	case FieldStack:
+		appendStackText(dst, f)
+		return
+	}
+	*dst = append(*dst, ' ')
+	*dst = append(*dst, f.Key...)
+	*dst = append(*dst, '=')
+	appendFieldValue(dst, f)
+}
+
+// End adds a newline unless the entry already ends with it.
+func (TextEncoder) End(dst *[]byte) {
+	if n := len(*dst); n == 0 || (*dst)[n-1] != '\n' {
+		*dst = append(*dst, '\n')
+	}
+}
+
+// =====================================================================================================================
+// ENCODER / JSON
+// =====================================================================================================================
+
+// JSONEncoder prints an entry as a JSON object: `{"time":"...","level":"info","prefix":"...","msg":"...","key":value}`
+type JSONEncoder struct{}
+
+func (JSONEncoder) Begin(dst *[]byte) {
+	*dst = append(*dst, '{')
+}
+
+// Header writes each key followed by a comma as `msg` key always follows.
+func (JSONEncoder) Header(dst *[]byte, h *Header) {
+	if h.Flag&(F_DATE|F_MMDD|F_TIME|F_MICROSEC) != 0 {
+		*dst = append(*dst, `"time":"`...)
+		appendTimeRFC3339(dst, h)
+		*dst = append(*dst, '"', ',')
+	}
+	if h.Level != 0 {
+		*dst = append(*dst, `"level":"`...)
+		*dst = append(*dst, h.Level.name()...)
+		*dst = append(*dst, '"', ',')
+	}
+	if h.Flag&F_PREFIX != 0 && len(h.Prefix) > 0 {
+		*dst = append(*dst, `"prefix":`...)
+		appendJSONBytes(dst, h.Prefix)
+		*dst = append(*dst, ',')
+	}
+	if loc := h.Caller.loc(h.Flag); loc != "" {
+		*dst = append(*dst, `"caller":`...)
+		appendJSONString(dst, loc)
+		*dst = append(*dst, ',')
+	}
+	if h.Flag&F_FUNC != 0 && h.Caller.Func != "" {
+		*dst = append(*dst, `"func":`...)
+		appendJSONString(dst, h.Caller.Func)
+		*dst = append(*dst, ',')
+	}
+}
+
+func (JSONEncoder) Message(dst *[]byte, msg []byte) {
+	*dst = append(*dst, `"msg":`...)
+	appendJSONBytes(dst, msg)
+}
+
+func (JSONEncoder) Field(dst *[]byte, f Field) {
+	*dst = append(*dst, ',')
+	appendJSONString(dst, f.Key)
+	*dst = append(*dst, ':')
+	appendFieldValueJSON(dst, f)
+}
+
+func (JSONEncoder) End(dst *[]byte) {
+	*dst = append(*dst, '}', '\n')
+}
+
+// =====================================================================================================================
+// ENCODER / LOGFMT
+// =====================================================================================================================
+
+// LogfmtEncoder prints an entry in logfmt: `time=... level=info prefix=... msg="..." key=value`
+type LogfmtEncoder struct{}
+
+func (LogfmtEncoder) Begin(dst *[]byte) {}
+
+// Header writes each pair followed by a space as `msg` key always follows.
+func (LogfmtEncoder) Header(dst *[]byte, h *Header) {
+	if h.Flag&(F_DATE|F_MMDD|F_TIME|F_MICROSEC) != 0 {
+		*dst = append(*dst, "time="...)
+		appendTimeRFC3339(dst, h)
+		*dst = append(*dst, ' ')
+	}
+	if h.Level != 0 {
+		*dst = append(*dst, "level="...)
+		*dst = append(*dst, h.Level.name()...)
+		*dst = append(*dst, ' ')
+	}
+	if h.Flag&F_PREFIX != 0 && len(h.Prefix) > 0 {
+		*dst = append(*dst, "prefix="...)
+		appendLogfmtBytes(dst, h.Prefix)
+		*dst = append(*dst, ' ')
+	}
+	if loc := h.Caller.loc(h.Flag); loc != "" {
+		*dst = append(*dst, "caller="...)
+		appendLogfmtString(dst, loc)
+		*dst = append(*dst, ' ')
+	}
+	if h.Flag&F_FUNC != 0 && h.Caller.Func != "" {
+		*dst = append(*dst, "func="...)
+		appendLogfmtString(dst, h.Caller.Func)
+		*dst = append(*dst, ' ')
+	}
+}
+
+func (LogfmtEncoder) Message(dst *[]byte, msg []byte) {
+	*dst = append(*dst, "msg="...)
+	appendLogfmtBytes(dst, msg)
+}
+
+func (LogfmtEncoder) Field(dst *[]byte, f Field) {
+	*dst = append(*dst, ' ')
+	appendLogfmtKey(dst, f.Key)
+	*dst = append(*dst, '=')
+	appendFieldValueLogfmt(dst, f)
+}
+
+func (LogfmtEncoder) End(dst *[]byte) {
+	*dst = append(*dst, '\n')
+}
+
+// appendTimeRFC3339 appends header's time in RFC3339 format; microseconds are added with F_MICROSEC.
+func appendTimeRFC3339(dst *[]byte, h *Header) {
+	t := h.Time
+	if h.Flag&F_UTC != 0 {
+		t = t.UTC()
+	}
+	if h.Flag&F_MICROSEC != 0 {
+		*dst = t.AppendFormat(*dst, "2006-01-02T15:04:05.000000Z07:00")
+	} else {
+		*dst = t.AppendFormat(*dst, time.RFC3339)
+	}
+}
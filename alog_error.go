@@ -0,0 +1,79 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import "io"
+
+// =====================================================================================================================
+// A LOGGER / WRITE ERROR
+// =====================================================================================================================
+
+// WriteError is an error from an output's Write
+type WriteError struct {
+	Writer io.Writer
+	Err    error
+}
+
+func (e *WriteError) Error() string {
+	return "alog: write failed: " + e.Err.Error()
+}
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler is called with *WriteError when a write fails. As it can be called while
+// the logger is locked, it must not log with the same logger.
+type ErrorHandler func(err error)
+
+// SetErrorHandler sets a function called when writing to an output fails
+func (l *ALogger) SetErrorHandler(fn ErrorHandler) {
+	l.errMu.Lock()
+	l.errFn = fn
+	l.errMu.Unlock()
+}
+
+// SetFallback sets a writer such as os.Stderr which receives entries failed to be written to an output.
+func (l *ALogger) SetFallback(w io.Writer) {
+	l.errMu.Lock()
+	l.fallback = w
+	l.errMu.Unlock()
+}
+
+// WriteErrors returns number of failed writes and the last error (*WriteError)
+func (l *ALogger) WriteErrors() (count uint64, last error) {
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	return l.errCount, l.errLast
+}
+
+// writeTo writes p to w, and reports an error if any. Every write to an output,
+// including the ones by async queues, goes through this.
+func (c *core) writeTo(w io.Writer, lvl Level, p []byte) {
+	var n int
+	var err error
+	if lw, ok := w.(LevelWriter); ok {
+		n, err = lw.WriteLevel(lvl, p)
+	} else {
+		n, err = w.Write(p)
+	}
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	if err == nil {
+		return
+	}
+
+	werr := &WriteError{Writer: w, Err: err}
+	c.errMu.Lock()
+	c.errCount++
+	c.errLast = werr
+	fn, fallback := c.errFn, c.fallback
+	c.errMu.Unlock()
+
+	if fn != nil {
+		fn(werr)
+	}
+	if fallback != nil && fallback != w {
+		fallback.Write(p)
+	}
+}
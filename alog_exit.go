@@ -0,0 +1,42 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import "os"
+
+// =====================================================================================================================
+// A LOGGER / EXIT
+// =====================================================================================================================
+
+// SetExitFunc sets a function called by Fatal methods after the entry is written.
+// By default (or when nil), os.Exit is used. This is mainly for tests.
+func (l *ALogger) SetExitFunc(fn func(code int)) {
+	l.mu.Lock()
+	l.exitFn = fn
+	l.mu.Unlock()
+}
+
+// AddExitHook registers a function to be run by Fatal methods before exiting.
+// Hooks run in the order they are added, after the output is flushed and closed.
+func (l *ALogger) AddExitHook(fn func()) {
+	l.mu.Lock()
+	l.exitHooks = append(l.exitHooks, fn)
+	l.mu.Unlock()
+}
+
+// exit flushes and closes the output, runs exit hooks, then calls the exit function with 1.
+func (l *ALogger) exit() {
+	l.Close()
+
+	l.mu.Lock()
+	hooks, fn := l.exitHooks, l.exitFn
+	l.mu.Unlock()
+
+	for _, h := range hooks {
+		h()
+	}
+	if fn == nil {
+		fn = os.Exit
+	}
+	fn(1)
+}
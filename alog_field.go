@@ -0,0 +1,152 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// =====================================================================================================================
+// FIELD
+// =====================================================================================================================
+type FieldType uint8
+
+const (
+	FieldString FieldType = iota
+	FieldInt
+	FieldUint
+	FieldFloat
+	FieldBool
+	FieldError
+	FieldJSON
+	FieldStack
+)
+
+// Field is a typed key/value pair for Printw and Printwl.
+// Values are kept in typed slots so they can be appended to the log buffer
+// without being boxed into an interface{}.
+type Field struct {
+	Key   string
+	Type  FieldType
+	Int   int64 // FieldInt, FieldUint (as bits), FieldBool (0 or 1)
+	Float float64
+	Str   string
+	Iface interface{} // FieldError, FieldJSON (json.Marshaler), FieldStack (*Stack)
+}
+
+func Str(key string, val string) Field {
+	return Field{Key: key, Type: FieldString, Str: val}
+}
+func Int(key string, val int) Field {
+	return Field{Key: key, Type: FieldInt, Int: int64(val)}
+}
+func Int64(key string, val int64) Field {
+	return Field{Key: key, Type: FieldInt, Int: val}
+}
+func Uint(key string, val uint64) Field {
+	return Field{Key: key, Type: FieldUint, Int: int64(val)}
+}
+func Float(key string, val float64) Field {
+	return Field{Key: key, Type: FieldFloat, Float: val}
+}
+func Bool(key string, val bool) Field {
+	if val {
+		return Field{Key: key, Type: FieldBool, Int: 1}
+	}
+	return Field{Key: key, Type: FieldBool}
+}
+
+// Err creates a field with a key "error"
+func Err(err error) Field {
+	return Field{Key: "error", Type: FieldError, Iface: err}
+}
+
+// appendFieldValue appends field's value without any quoting
+func appendFieldValue(dst *[]byte, f Field) {
+	switch f.Type {
+	case FieldString:
+		*dst = append(*dst, f.Str...)
+	case FieldInt:
+		*dst = strconv.AppendInt(*dst, f.Int, 10)
+	case FieldUint:
+		*dst = strconv.AppendUint(*dst, uint64(f.Int), 10)
+	case FieldFloat:
+		*dst = strconv.AppendFloat(*dst, f.Float, 'f', -1, 64)
+	case FieldBool:
+		*dst = strconv.AppendBool(*dst, f.Int == 1)
+	case FieldError:
+		if err, ok := f.Iface.(error); ok && err != nil {
+			*dst = append(*dst, err.Error()...)
+		} else {
+			*dst = append(*dst, "nil"...)
+		}
+	case FieldJSON:
+		*dst = append(*dst, marshalJSON(f.Iface)...)
+	case FieldStack:
+		appendStackJSON(dst, f)
+	default:
+		*dst = append(*dst, unsuppType...)
+	}
+}
+
+var emptyJSON = []byte("{}")
+
+// marshalJSON returns JSON from json.Marshaler; `{}` if not available
+func marshalJSON(v interface{}) []byte {
+	if m, ok := v.(json.Marshaler); ok && m != nil {
+		if b, err := m.MarshalJSON(); err == nil && len(b) > 0 {
+			return b
+		}
+	}
+	return emptyJSON
+}
+
+// appendFieldValueJSON appends field's value as a JSON value
+func appendFieldValueJSON(dst *[]byte, f Field) {
+	switch f.Type {
+	case FieldString:
+		appendJSONString(dst, f.Str)
+	case FieldFloat:
+		if math.IsNaN(f.Float) || math.IsInf(f.Float, 0) { // not valid as a JSON number
+			*dst = append(*dst, '"')
+			*dst = strconv.AppendFloat(*dst, f.Float, 'f', -1, 64)
+			*dst = append(*dst, '"')
+		} else {
+			*dst = strconv.AppendFloat(*dst, f.Float, 'f', -1, 64)
+		}
+	case FieldError:
+		if err, ok := f.Iface.(error); ok && err != nil {
+			appendJSONString(dst, err.Error())
+		} else {
+			*dst = append(*dst, "null"...)
+		}
+	case FieldInt, FieldUint, FieldBool, FieldJSON, FieldStack:
+		appendFieldValue(dst, f)
+	default:
+		appendJSONString(dst, string(unsuppType))
+	}
+}
+
+// appendFieldValueLogfmt appends field's value, quoting it when needed
+func appendFieldValueLogfmt(dst *[]byte, f Field) {
+	switch f.Type {
+	case FieldString:
+		appendLogfmtString(dst, f.Str)
+	case FieldError:
+		if err, ok := f.Iface.(error); ok && err != nil {
+			appendLogfmtString(dst, err.Error())
+		} else {
+			*dst = append(*dst, "nil"...)
+		}
+	case FieldJSON:
+		appendLogfmtBytes(dst, marshalJSON(f.Iface))
+	case FieldStack:
+		var b []byte
+		appendStackJSON(&b, f)
+		appendLogfmtBytes(dst, b)
+	default:
+		appendFieldValue(dst, f)
+	}
+}
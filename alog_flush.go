@@ -0,0 +1,66 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import "time"
+
+// =====================================================================================================================
+// A LOGGER / AUTO FLUSH
+// =====================================================================================================================
+
+// SetFlushInterval flushes the logger every d from a background goroutine, so entries kept in
+// the buffer (F_USE_BUF_*) or the async queue don't wait until the buffer is full.
+// d <= 0 stops it. Close also stops it.
+func (l *ALogger) SetFlushInterval(d time.Duration) {
+	l.mu.Lock()
+	prev := l.ticker
+	l.ticker = nil
+	if d > 0 {
+		l.ticker = startFlushTicker(l, d)
+	}
+	l.mu.Unlock()
+
+	if prev != nil { // the goroutine may be waiting for l.mu in Flush
+		prev.stop()
+	}
+}
+
+// SetFlushLevel makes entries of given levels write out the buffer immediately,
+// eg. SetFlushLevel(ERROR|FATAL). 0 turns it off (default).
+func (l *ALogger) SetFlushLevel(lvl Level) {
+	l.mu.Lock()
+	l.flushLvl = lvl
+	l.mu.Unlock()
+}
+
+type flushTicker struct {
+	quit chan struct{}
+	done chan struct{}
+}
+
+func startFlushTicker(l *ALogger, d time.Duration) *flushTicker {
+	f := &flushTicker{
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(f.done)
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				l.Flush()
+			case <-f.quit:
+				return
+			}
+		}
+	}()
+	return f
+}
+
+// stop stops the goroutine and waits for it to exit
+func (f *flushTicker) stop() {
+	close(f.quit)
+	<-f.done
+}
@@ -0,0 +1,771 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// =====================================================================================================================
+// PRINTF FORMATTING
+// =====================================================================================================================
+// appendPrintf is a version of fmt.Sprintf formatting integers, floats, complex numbers, strings,
+// []byte, bool and pointers (%p) without allocation. Verbs, flags, width and precision work the same
+// as fmt except %f, which uses 2 decimal places by default. (see SetFloatPrecision)
+// Other types such as structs, maps and slices, fmt.Formatter, fmt.GoStringer for %#v, and verbs
+// not supported here are formatted by fmt.
+// As error and fmt.Stringer methods may keep their receiver, Printf's arguments are on the heap.
+
+// fmtSpec is a parsed format specifier; wid and prec are -1 when not given.
+// fprec is the precision of %f when prec is not given.
+type fmtSpec struct {
+	minus, plus, sharp, space, zero bool
+	wid, prec, fprec                int
+}
+
+const defaultFloatPrec = 2
+
+func appendPrintf(dst *[]byte, format string, a []interface{}, fprec int) {
+	argIdx := 0
+	for i := 0; i < len(format); {
+		j := i
+		for j < len(format) && format[j] != '%' {
+			j++
+		}
+		*dst = append(*dst, format[i:j]...)
+		if j >= len(format) {
+			break
+		}
+		j++ // skip '%'
+
+		s := fmtSpec{wid: -1, prec: -1, fprec: fprec}
+	flags:
+		for ; j < len(format); j++ {
+			switch format[j] {
+			case '-':
+				s.minus, s.zero = true, false
+			case '+':
+				s.plus = true
+			case '#':
+				s.sharp = true
+			case ' ':
+				s.space = true
+			case '0':
+				s.zero = !s.minus
+			default:
+				break flags
+			}
+		}
+
+		// width
+		if j < len(format) && format[j] == '*' {
+			j++
+			if argIdx < len(a) {
+				if w, ok := a[argIdx].(int); ok {
+					if w < 0 {
+						s.minus, s.zero, w = true, false, -w
+					}
+					s.wid = w
+				} else {
+					*dst = append(*dst, "%!(BADWIDTH)"...)
+				}
+				argIdx++
+			} else {
+				*dst = append(*dst, "%!(BADWIDTH)"...)
+			}
+		} else {
+			s.wid, j = parseNum(format, j)
+		}
+
+		// precision
+		if j < len(format) && format[j] == '.' {
+			j++
+			if j < len(format) && format[j] == '*' {
+				j++
+				if argIdx < len(a) {
+					if p, ok := a[argIdx].(int); ok && p >= 0 {
+						s.prec = p
+					} else {
+						*dst = append(*dst, "%!(BADPREC)"...)
+					}
+					argIdx++
+				} else {
+					*dst = append(*dst, "%!(BADPREC)"...)
+				}
+			} else {
+				s.prec, j = parseNum(format, j)
+				if s.prec < 0 { // `%.f` means a precision of 0
+					s.prec = 0
+				}
+			}
+		}
+
+		if j >= len(format) {
+			*dst = append(*dst, "%!(NOVERB)"...)
+			break
+		}
+		verb, size := utf8.DecodeRuneInString(format[j:])
+		j += size
+		i = j
+
+		if verb == '%' {
+			*dst = append(*dst, '%')
+			continue
+		}
+		if argIdx >= len(a) {
+			*dst = append(*dst, '%', '!')
+			appendRune(dst, verb)
+			*dst = append(*dst, "(MISSING)"...)
+			continue
+		}
+		arg := a[argIdx]
+		argIdx++
+
+		argStart := len(*dst)
+		if verb >= utf8.RuneSelf || !appendArg(dst, &s, byte(verb), arg) {
+			*dst = (*dst)[:argStart]
+			appendFmt(dst, &s, verb, arg)
+		}
+	}
+
+	// `%!(EXTRA type=value, ...)` for arguments not used, same as fmt
+	if argIdx < len(a) {
+		*dst = append(*dst, "%!(EXTRA "...)
+		for k, arg := range a[argIdx:] {
+			if k > 0 {
+				*dst = append(*dst, ',', ' ')
+			}
+			if arg == nil {
+				*dst = append(*dst, "<nil>"...)
+				continue
+			}
+			*dst = append(*dst, reflect.TypeOf(arg).String()...)
+			*dst = append(*dst, '=')
+			s := fmtSpec{wid: -1, prec: -1, fprec: fprec}
+			argStart := len(*dst)
+			if !appendArg(dst, &s, 'v', arg) {
+				*dst = (*dst)[:argStart]
+				appendFmt(dst, &s, 'v', arg)
+			}
+		}
+		*dst = append(*dst, ')')
+	}
+}
+
+// appendFmt formats an argument with fmt for a type or a verb not supported here,
+// including a wrong verb such as `%!d(string=a)`.
+func appendFmt(dst *[]byte, s *fmtSpec, verb rune, arg interface{}) {
+	f := make([]byte, 0, 16)
+	f = append(f, '%')
+	if s.minus {
+		f = append(f, '-')
+	}
+	if s.plus {
+		f = append(f, '+')
+	}
+	if s.sharp {
+		f = append(f, '#')
+	}
+	if s.space {
+		f = append(f, ' ')
+	}
+	if s.zero {
+		f = append(f, '0')
+	}
+	if s.wid >= 0 {
+		f = strconv.AppendInt(f, int64(s.wid), 10)
+	}
+	if s.prec >= 0 {
+		f = append(f, '.')
+		f = strconv.AppendInt(f, int64(s.prec), 10)
+	}
+	appendRune(&f, verb)
+	fmt.Fprintf((*tinyBuffer)(dst), string(f), arg)
+}
+
+// parseNum parses digits from s[i:]; returns -1 when there's no digit.
+func parseNum(s string, i int) (num int, next int) {
+	num = -1
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		if num < 0 {
+			num = 0
+		}
+		if num < 1e6 { // fmt's limit
+			num = num*10 + int(s[i]-'0')
+		}
+	}
+	return num, i
+}
+
+// appendArg appends a formatted argument, returns false when it should be handled by fmt.
+func appendArg(dst *[]byte, s *fmtSpec, verb byte, arg interface{}) bool {
+	switch verb {
+	case 'T':
+		if arg == nil {
+			return appendStr(dst, s, 's', "<nil>")
+		}
+		return appendStr(dst, s, 's', reflect.TypeOf(arg).String())
+	case 'p':
+		return appendPointer(dst, s, arg)
+	}
+
+	switch v := arg.(type) {
+	case nil:
+		if verb != 'v' {
+			return false
+		}
+		return appendStr(dst, s, verb, "<nil>")
+	case string:
+		return appendStr(dst, s, verb, v)
+	case []byte:
+		if verb == 'v' || verb == 'd' {
+			if s.sharp || s.plus || s.space || s.zero || s.prec >= 0 {
+				return false
+			}
+			start := len(*dst)
+			*dst = append(*dst, '[')
+			for k := 0; k < len(v); k++ {
+				if k > 0 {
+					*dst = append(*dst, ' ')
+				}
+				*dst = strconv.AppendUint(*dst, uint64(v[k]), 10)
+			}
+			*dst = append(*dst, ']')
+			pad(dst, start, s, false)
+			return true
+		}
+		return appendBytes(dst, s, verb, v)
+	case bool:
+		if verb != 't' && verb != 'v' {
+			return false
+		}
+		return appendStr(dst, s, 's', strconv.FormatBool(v))
+	case int:
+		return appendInt(dst, s, verb, uint64(absInt64(int64(v))), v < 0)
+	case int8:
+		return appendInt(dst, s, verb, uint64(absInt64(int64(v))), v < 0)
+	case int16:
+		return appendInt(dst, s, verb, uint64(absInt64(int64(v))), v < 0)
+	case int32:
+		return appendInt(dst, s, verb, uint64(absInt64(int64(v))), v < 0)
+	case int64:
+		return appendInt(dst, s, verb, uint64(absInt64(v)), v < 0)
+	case uint:
+		return appendInt(dst, s, verb, uint64(v), false)
+	case uint8:
+		return appendInt(dst, s, verb, uint64(v), false)
+	case uint16:
+		return appendInt(dst, s, verb, uint64(v), false)
+	case uint32:
+		return appendInt(dst, s, verb, uint64(v), false)
+	case uint64:
+		return appendInt(dst, s, verb, v, false)
+	case uintptr:
+		return appendInt(dst, s, verb, uint64(v), false)
+	case float32:
+		return appendFloat(dst, s, verb, float64(v), 32)
+	case float64:
+		return appendFloat(dst, s, verb, v, 64)
+	case complex64:
+		return appendComplex(dst, s, verb, complex128(v), 32)
+	case complex128:
+		return appendComplex(dst, s, verb, v, 64)
+	case fmt.Formatter:
+		return false
+	case fmt.GoStringer:
+		if s.sharp && verb == 'v' {
+			return false
+		}
+	}
+	switch arg.(type) {
+	case error, fmt.Stringer:
+		if !s.sharp && (verb == 'v' || verb == 's' || verb == 'q' || verb == 'x' || verb == 'X') {
+			return appendStringer(dst, s, verb, arg)
+		}
+	}
+	return appendKind(dst, s, verb, arg)
+}
+
+// appendKind formats a named type such as time.Duration by its underlying kind.
+func appendKind(dst *[]byte, s *fmtSpec, verb byte, arg interface{}) bool {
+	// Non-pointer shaped values are stored in an interface as a pointer to the value.
+	p := ifaceData(&arg)
+	switch t := reflect.TypeOf(arg); t.Kind() {
+	case reflect.Bool:
+		return appendArg(dst, s, verb, *(*bool)(p))
+	case reflect.Int:
+		return appendArg(dst, s, verb, *(*int)(p))
+	case reflect.Int8:
+		return appendArg(dst, s, verb, *(*int8)(p))
+	case reflect.Int16:
+		return appendArg(dst, s, verb, *(*int16)(p))
+	case reflect.Int32:
+		return appendArg(dst, s, verb, *(*int32)(p))
+	case reflect.Int64:
+		return appendArg(dst, s, verb, *(*int64)(p))
+	case reflect.Uint:
+		return appendArg(dst, s, verb, *(*uint)(p))
+	case reflect.Uint8:
+		return appendArg(dst, s, verb, *(*uint8)(p))
+	case reflect.Uint16:
+		return appendArg(dst, s, verb, *(*uint16)(p))
+	case reflect.Uint32:
+		return appendArg(dst, s, verb, *(*uint32)(p))
+	case reflect.Uint64:
+		return appendArg(dst, s, verb, *(*uint64)(p))
+	case reflect.Uintptr:
+		return appendArg(dst, s, verb, *(*uintptr)(p))
+	case reflect.Float32:
+		return appendArg(dst, s, verb, *(*float32)(p))
+	case reflect.Float64:
+		return appendArg(dst, s, verb, *(*float64)(p))
+	case reflect.Complex64:
+		return appendArg(dst, s, verb, *(*complex64)(p))
+	case reflect.Complex128:
+		return appendArg(dst, s, verb, *(*complex128)(p))
+	case reflect.String:
+		return appendArg(dst, s, verb, *(*string)(p))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return appendArg(dst, s, verb, *(*[]byte)(p))
+		}
+	}
+	return false
+}
+
+// ifaceData returns the data word of an interface
+func ifaceData(arg *interface{}) unsafe.Pointer {
+	return (*[2]unsafe.Pointer)(unsafe.Pointer(arg))[1]
+}
+
+// appendStringer formats Error() or String() of arg. Like fmt, a panic in the method is printed as
+// `%!v(PANIC=String method: ...)`, or `<nil>` when the receiver is a nil pointer.
+func appendStringer(dst *[]byte, s *fmtSpec, verb byte, arg interface{}) (ok bool) {
+	start := len(*dst)
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		*dst = (*dst)[:start]
+		ok = true
+		if v := reflect.ValueOf(arg); v.Kind() == reflect.Ptr && v.IsNil() {
+			appendStr(dst, s, 's', "<nil>")
+			return
+		}
+		method := "String"
+		if _, isErr := arg.(error); isErr {
+			method = "Error"
+		}
+		*dst = append(*dst, '%', '!', verb)
+		*dst = append(*dst, "(PANIC="...)
+		*dst = append(*dst, method...)
+		*dst = append(*dst, " method: "...)
+		fmt.Fprint((*tinyBuffer)(dst), r)
+		*dst = append(*dst, ')')
+	}()
+
+	var str string
+	switch v := arg.(type) {
+	case error:
+		str = v.Error()
+	case fmt.Stringer:
+		str = v.String()
+	}
+	return appendStr(dst, s, verb, str)
+}
+
+// appendPointer formats %p: `0x` followed by an address in hex. `#` flag omits `0x`.
+func appendPointer(dst *[]byte, s *fmtSpec, arg interface{}) bool {
+	if arg == nil {
+		return false
+	}
+	// The data word of an interface is the value itself for pointer shaped kinds,
+	// or a pointer to the value (where a slice or a func starts with its pointer) otherwise.
+	data := ifaceData(&arg)
+	var u uintptr
+	switch reflect.TypeOf(arg).Kind() {
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Map, reflect.Chan:
+		u = uintptr(data)
+	case reflect.Slice, reflect.Func:
+		if data != nil { // nil func
+			u = *(*uintptr)(data)
+		}
+	default:
+		return false
+	}
+	start := len(*dst)
+	if !s.sharp {
+		*dst = append(*dst, '0', 'x')
+	}
+	*dst = strconv.AppendUint(*dst, uint64(u), 16)
+	pad(dst, start, s, false)
+	return true
+}
+
+// absInt64 returns an absolute value; math.MinInt64 stays the same but its bits as uint64 are correct.
+func absInt64(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// appendStr formats a string for verbs `v`, `s`, `q`, `x` and `X`.
+func appendStr(dst *[]byte, s *fmtSpec, verb byte, str string) bool {
+	start := len(*dst)
+	switch verb {
+	case 'v', 's':
+		if s.sharp && verb == 'v' {
+			return false
+		}
+		if s.prec >= 0 {
+			str = truncRunes(str, s.prec)
+		}
+		*dst = append(*dst, str...)
+	case 'q':
+		if s.prec >= 0 {
+			str = truncRunes(str, s.prec)
+		}
+		if s.sharp && strconv.CanBackquote(str) {
+			*dst = append(*dst, '`')
+			*dst = append(*dst, str...)
+			*dst = append(*dst, '`')
+		} else if s.plus {
+			*dst = strconv.AppendQuoteToASCII(*dst, str)
+		} else {
+			*dst = strconv.AppendQuote(*dst, str)
+		}
+	case 'x', 'X':
+		n := len(str)
+		if s.prec >= 0 && s.prec < n {
+			n = s.prec
+		}
+		for k := 0; k < n; k++ {
+			appendHexByte(dst, s, verb, str[k], k)
+		}
+	default:
+		return false
+	}
+	pad(dst, start, s, s.zero)
+	return true
+}
+
+// appendBytes is same as appendStr but for []byte
+func appendBytes(dst *[]byte, s *fmtSpec, verb byte, b []byte) bool {
+	start := len(*dst)
+	switch verb {
+	case 's':
+		if s.prec >= 0 {
+			n := 0
+			for k := 0; k < len(b) && n < s.prec; n++ {
+				_, size := utf8.DecodeRune(b[k:])
+				k += size
+				*dst = append(*dst, b[k-size:k]...)
+			}
+		} else {
+			*dst = append(*dst, b...)
+		}
+	case 'x', 'X':
+		n := len(b)
+		if s.prec >= 0 && s.prec < n {
+			n = s.prec
+		}
+		for k := 0; k < n; k++ {
+			appendHexByte(dst, s, verb, b[k], k)
+		}
+	case 'q':
+		return appendStr(dst, s, verb, string(b))
+	default:
+		return false
+	}
+	pad(dst, start, s, s.zero)
+	return true
+}
+
+// appendHexByte appends i-th byte of a string in hex: a space between bytes with ` ` flag,
+// and `0x` before the first (or every with ` ` flag) byte with `#` flag.
+func appendHexByte(dst *[]byte, s *fmtSpec, verb byte, c byte, i int) {
+	if i > 0 && s.space {
+		*dst = append(*dst, ' ')
+	}
+	if s.sharp && (i == 0 || s.space) {
+		*dst = append(*dst, '0', verb)
+	}
+	if verb == 'X' {
+		*dst = append(*dst, "0123456789ABCDEF"[c>>4], "0123456789ABCDEF"[c&0xF])
+	} else {
+		*dst = append(*dst, hexDigits[c>>4], hexDigits[c&0xF])
+	}
+}
+
+// appendInt formats an integer; u is an absolute value and neg is its sign.
+func appendInt(dst *[]byte, s *fmtSpec, verb byte, u uint64, neg bool) bool {
+	start := len(*dst)
+	base := uint64(10)
+	prefix := ""
+	switch verb {
+	case 'd', 'v':
+		if s.sharp && verb == 'v' {
+			return false
+		}
+	case 'b':
+		base = 2
+		if s.sharp {
+			prefix = "0b"
+		}
+	case 'o', 'O':
+		base = 8
+		if verb == 'O' {
+			prefix = "0o"
+		}
+	case 'x':
+		base = 16
+		if s.sharp {
+			prefix = "0x"
+		}
+	case 'X':
+		base = 16
+		if s.sharp {
+			prefix = "0X"
+		}
+	case 'c':
+		if neg || s.plus || s.sharp || u > utf8.MaxRune {
+			return false
+		}
+		appendRune(dst, rune(u))
+		pad(dst, start, s, s.zero)
+		return true
+	case 'U':
+		if neg {
+			return false
+		}
+		*dst = append(*dst, 'U', '+')
+		var b [16]byte
+		hex := strconv.AppendUint(b[:0], u, 16)
+		prec := 4
+		if s.prec > prec {
+			prec = s.prec
+		}
+		for k := len(hex); k < prec; k++ {
+			*dst = append(*dst, '0')
+		}
+		for k := 0; k < len(hex); k++ {
+			c := hex[k]
+			if c >= 'a' {
+				c -= 'a' - 'A'
+			}
+			*dst = append(*dst, c)
+		}
+		if s.sharp && u <= utf8.MaxRune && strconv.IsPrint(rune(u)) {
+			*dst = append(*dst, ' ', '\'')
+			appendRune(dst, rune(u))
+			*dst = append(*dst, '\'')
+		}
+		pad(dst, start, s, false)
+		return true
+	case 'q':
+		if neg || s.sharp || u > utf8.MaxRune {
+			return false
+		}
+		if s.plus {
+			*dst = strconv.AppendQuoteRuneToASCII(*dst, rune(u))
+		} else {
+			*dst = strconv.AppendQuoteRune(*dst, rune(u))
+		}
+		pad(dst, start, s, s.zero)
+		return true
+	default:
+		return false
+	}
+
+	// digits from the end of the array
+	var b [64]byte
+	i := len(b)
+	digits := "0123456789abcdef"
+	if verb == 'X' {
+		digits = "0123456789ABCDEF"
+	}
+	if u != 0 || s.prec != 0 { // `%.0d` of 0 prints nothing
+		for u >= base {
+			i--
+			b[i] = digits[u%base]
+			u /= base
+		}
+		i--
+		b[i] = digits[u]
+	}
+	if verb == 'o' && s.sharp && (i == len(b) || b[i] != '0') {
+		prefix = "0"
+	}
+
+	zeros := 0
+	if s.prec > len(b)-i {
+		zeros = s.prec - (len(b) - i)
+	}
+	sign := byte(0)
+	if neg {
+		sign = '-'
+	} else if s.plus {
+		sign = '+'
+	} else if s.space {
+		sign = ' '
+	}
+	if s.zero && s.prec < 0 && s.wid > 0 {
+		n := len(b) - i + len(prefix)
+		if sign != 0 {
+			n++
+		}
+		if s.wid > n {
+			zeros = s.wid - n
+		}
+	}
+
+	if sign != 0 {
+		*dst = append(*dst, sign)
+	}
+	*dst = append(*dst, prefix...)
+	for ; zeros > 0; zeros-- {
+		*dst = append(*dst, '0')
+	}
+	*dst = append(*dst, b[i:]...)
+	pad(dst, start, s, false)
+	return true
+}
+
+// appendFloat formats a float for verbs `v`, `e`, `E`, `f`, `F`, `g` and `G`.
+func appendFloat(dst *[]byte, s *fmtSpec, verb byte, f float64, bitSize int) bool {
+	if s.sharp {
+		return false
+	}
+	start := len(*dst)
+	prec := s.prec
+	switch verb {
+	case 'v':
+		verb = 'g'
+	case 'e', 'E':
+		if prec < 0 {
+			prec = 6
+		}
+	case 'f', 'F':
+		if prec < 0 {
+			prec = s.fprec
+		}
+	case 'g', 'G':
+	default:
+		return false
+	}
+
+	// sign: same as fmt, +Inf always has one unless replaced by a space
+	switch {
+	case math.IsInf(f, 1):
+		if s.space && !s.plus {
+			*dst = append(*dst, " Inf"...)
+		} else {
+			*dst = append(*dst, "+Inf"...)
+		}
+		pad(dst, start, s, false)
+		return true
+	case f != f || !math.Signbit(f):
+		if s.plus {
+			*dst = append(*dst, '+')
+		} else if s.space {
+			*dst = append(*dst, ' ')
+		}
+	}
+	if verb == 'f' || verb == 'F' {
+		ftoa(dst, f, prec, bitSize)
+	} else {
+		*dst = strconv.AppendFloat(*dst, f, verb, prec, bitSize)
+	}
+
+	// zero padding after the sign; not for Inf or NaN
+	if s.zero && s.wid > 0 && f == f && f-f == 0 {
+		n := utf8.RuneCount((*dst)[start:])
+		if s.wid > n {
+			signLen := 0
+			if c := (*dst)[start]; c == '-' || c == '+' || c == ' ' {
+				signLen = 1
+			}
+			insert(dst, start+signLen, s.wid-n, '0')
+		}
+	}
+	pad(dst, start, s, false)
+	return true
+}
+
+// appendComplex formats a complex number as `(real+imagi)` for float verbs.
+func appendComplex(dst *[]byte, s *fmtSpec, verb byte, c complex128, bitSize int) bool {
+	if s.sharp || s.wid > 0 {
+		return false
+	}
+	*dst = append(*dst, '(')
+	if !appendFloat(dst, s, verb, real(c), bitSize) {
+		return false
+	}
+	plus := s.plus
+	s.plus = true // imaginary part always has a sign
+	ok := appendFloat(dst, s, verb, imag(c), bitSize)
+	s.plus = plus
+	if !ok {
+		return false
+	}
+	*dst = append(*dst, 'i', ')')
+	return true
+}
+
+// pad pads (*dst)[start:] to the width with spaces (or zeros).
+func pad(dst *[]byte, start int, s *fmtSpec, zero bool) {
+	if s.wid <= 0 {
+		return
+	}
+	n := utf8.RuneCount((*dst)[start:])
+	if n >= s.wid {
+		return
+	}
+	if s.minus {
+		for k := s.wid - n; k > 0; k-- {
+			*dst = append(*dst, ' ')
+		}
+		return
+	}
+	if zero {
+		insert(dst, start, s.wid-n, '0')
+	} else {
+		insert(dst, start, s.wid-n, ' ')
+	}
+}
+
+// insert inserts n of c at (*dst)[at].
+func insert(dst *[]byte, at int, n int, c byte) {
+	end := len(*dst)
+	for k := 0; k < n; k++ {
+		*dst = append(*dst, c)
+	}
+	copy((*dst)[at+n:], (*dst)[at:end])
+	for k := at; k < at+n; k++ {
+		(*dst)[k] = c
+	}
+}
+
+func appendRune(dst *[]byte, r rune) {
+	var b [utf8.UTFMax]byte
+	n := utf8.EncodeRune(b[:], r)
+	*dst = append(*dst, b[:n]...)
+}
+
+// truncRunes returns the first n runes of s
+func truncRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
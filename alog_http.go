@@ -0,0 +1,316 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// =====================================================================================================================
+// HTTP WRITER
+// =====================================================================================================================
+
+// HTTPWriter is an io.WriteCloser posting entries in batches to an HTTP endpoint, as newline-delimited
+// bodies (`application/x-ndjson` for JSON entries). A batch is sent when it has maxEntries entries
+// or maxBytes bytes, or when its first entry is older than maxAge (see SetBatch).
+//
+//	w := alog.NewHTTPWriter("https://logs.example.com/ingest")
+//	l := alog.New(w, "", alog.F_JSON)
+//	defer l.Close() // sends the last batch
+//
+// Batches are sent in the background, up to SetMaxInFlight at a time; when that many are in flight,
+// Write waits. Failed requests are retried with backoff on network errors, 429 and 5xx.
+// Entries of a batch which couldn't be sent are counted by Dropped and reported to the error handler.
+// When more than one batch is in flight, they may arrive out of order.
+type HTTPWriter struct {
+	mu     sync.Mutex
+	url    string
+	client *http.Client
+	header http.Header
+	gzip   bool
+
+	maxEntries int
+	maxBytes   int
+	maxAge     time.Duration
+
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	batch   []byte
+	entries int
+	gen     uint64 // incremented for every batch taken; to ignore a stale age timer
+	timer   *time.Timer
+	free    [][]byte // batch buffers to reuse
+
+	sem     chan struct{} // in-flight batches
+	wg      sync.WaitGroup
+	dropped uint64 // entries
+	errFn   ErrorHandler
+	closed  bool
+}
+
+// NewHTTPWriter returns a writer posting to url. By default, a batch is up to 1000 entries, 1MB,
+// or 1 second old, 2 batches can be in flight, and a request is retried 3 times.
+func NewHTTPWriter(url string) *HTTPWriter {
+	return &HTTPWriter{
+		url:        url,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		header:     make(http.Header),
+		maxEntries: 1000,
+		maxBytes:   1 << 20,
+		maxAge:     time.Second,
+		retries:    3,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 10 * time.Second,
+		sem:        make(chan struct{}, 2),
+	}
+}
+
+// SetBatch sets when a batch is sent. Zero or less disables each condition.
+func (w *HTTPWriter) SetBatch(maxEntries, maxBytes int, maxAge time.Duration) {
+	w.mu.Lock()
+	w.maxEntries, w.maxBytes, w.maxAge = maxEntries, maxBytes, maxAge
+	w.mu.Unlock()
+}
+
+// SetGzip compresses request bodies with `Content-Encoding: gzip`
+func (w *HTTPWriter) SetGzip(on bool) {
+	w.mu.Lock()
+	w.gzip = on
+	w.mu.Unlock()
+}
+
+// SetRetry sets max number of retries of a request, and the wait before a retry which starts at
+// min and doubles up to max.
+func (w *HTTPWriter) SetRetry(retries int, min, max time.Duration) {
+	if max < min {
+		max = min
+	}
+	w.mu.Lock()
+	w.retries, w.minBackoff, w.maxBackoff = retries, min, max
+	w.mu.Unlock()
+}
+
+// SetMaxInFlight sets max number of batches being sent at a time. It should be set before writing.
+func (w *HTTPWriter) SetMaxInFlight(n int) {
+	if n < 1 {
+		n = 1
+	}
+	w.mu.Lock()
+	w.sem = make(chan struct{}, n)
+	w.mu.Unlock()
+}
+
+// SetHeader sets a header of requests such as `Authorization`
+func (w *HTTPWriter) SetHeader(key, value string) {
+	w.mu.Lock()
+	w.header.Set(key, value)
+	w.mu.Unlock()
+}
+
+// SetClient sets the HTTP client; by default, a client with 30 seconds timeout.
+func (w *HTTPWriter) SetClient(c *http.Client) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	w.mu.Lock()
+	w.client = c
+	w.mu.Unlock()
+}
+
+// SetErrorHandler sets a function called with *WriteError when a batch couldn't be sent.
+// It's called from a background goroutine.
+func (w *HTTPWriter) SetErrorHandler(fn ErrorHandler) {
+	w.mu.Lock()
+	w.errFn = fn
+	w.mu.Unlock()
+}
+
+// Dropped returns number of entries which couldn't be sent
+func (w *HTTPWriter) Dropped() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+// Write adds p to the batch. Each line of p is counted as an entry.
+func (w *HTTPWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return 0, os.ErrClosed
+	}
+	if w.entries == 0 && w.maxAge > 0 {
+		gen := w.gen
+		w.timer = time.AfterFunc(w.maxAge, func() { w.flushAge(gen) })
+	}
+	w.batch = append(w.batch, p...)
+	if c := bytes.Count(p, []byte{'\n'}); c > 0 {
+		w.entries += c
+	} else {
+		w.entries++
+	}
+
+	var b []byte
+	var entries int
+	var sem chan struct{}
+	if (w.maxEntries > 0 && w.entries >= w.maxEntries) || (w.maxBytes > 0 && len(w.batch) >= w.maxBytes) {
+		b, entries, sem = w.takeBatch()
+	}
+	w.mu.Unlock()
+
+	if b != nil {
+		w.send(b, entries, sem)
+	}
+	return len(p), nil
+}
+
+// Flush sends the current batch, and waits until every batch in flight is done.
+func (w *HTTPWriter) Flush() error {
+	w.mu.Lock()
+	b, entries, sem := w.takeBatch()
+	w.mu.Unlock()
+
+	if b != nil {
+		w.send(b, entries, sem)
+	}
+	w.wg.Wait()
+	return nil
+}
+
+// Close sends the current batch, and waits until every batch in flight is done.
+func (w *HTTPWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	return w.Flush()
+}
+
+// flushAge sends the batch of gen when it's still current
+func (w *HTTPWriter) flushAge(gen uint64) {
+	w.mu.Lock()
+	if gen != w.gen {
+		w.mu.Unlock()
+		return
+	}
+	b, entries, sem := w.takeBatch()
+	w.mu.Unlock()
+
+	if b != nil {
+		w.send(b, entries, sem)
+	}
+}
+
+// takeBatch takes the current batch if any, to be passed to send. Caller must hold w.mu.
+func (w *HTTPWriter) takeBatch() (b []byte, entries int, sem chan struct{}) {
+	if w.entries == 0 {
+		return nil, 0, nil
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	b, entries = w.batch, w.entries
+	w.batch, w.entries = nil, 0
+	if n := len(w.free); n > 0 {
+		w.batch, w.free = w.free[n-1], w.free[:n-1]
+	}
+	w.gen++
+	w.wg.Add(1)
+	return b, entries, w.sem
+}
+
+// send posts b in the background, after waiting for a slot of sem.
+func (w *HTTPWriter) send(b []byte, entries int, sem chan struct{}) {
+	sem <- struct{}{}
+	go func() {
+		w.post(b, entries)
+		w.mu.Lock()
+		w.free = append(w.free, b[:0])
+		w.mu.Unlock()
+		<-sem
+		w.wg.Done()
+	}()
+}
+
+// post posts a batch, retrying on temporary failures
+func (w *HTTPWriter) post(b []byte, entries int) {
+	w.mu.Lock()
+	client, url, header, gz := w.client, w.url, w.header.Clone(), w.gzip
+	retries, backoff, maxBackoff := w.retries, w.minBackoff, w.maxBackoff
+	w.mu.Unlock()
+
+	body := b
+	if gz {
+		var zb bytes.Buffer
+		zw := gzip.NewWriter(&zb)
+		zw.Write(b)
+		zw.Close()
+		body = zb.Bytes()
+		header.Set("Content-Encoding", "gzip")
+	}
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/x-ndjson")
+	}
+
+	for i := 0; ; i++ {
+		retry, err := w.do(client, url, header, body)
+		if err == nil {
+			return
+		}
+		if !retry || i >= retries {
+			w.fail(entries, err)
+			return
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// do makes a request, and returns whether it can be retried when failed.
+func (w *HTTPWriter) do(client *http.Client, url string, header http.Header, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = errors.New("alog: http status " + resp.Status)
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// fail counts entries of a batch which couldn't be sent, and reports the error
+func (w *HTTPWriter) fail(entries int, err error) {
+	w.mu.Lock()
+	w.dropped += uint64(entries)
+	fn := w.errFn
+	w.mu.Unlock()
+
+	if fn != nil {
+		fn(&WriteError{Writer: w, Err: err})
+	}
+}
@@ -0,0 +1,105 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+// =====================================================================================================================
+// A LOGGER / LEVEL METHODS
+// =====================================================================================================================
+// Shortcuts of Printl, Printfl, Printjl and Printwl for each level.
+// eg. `l.Warnf("disk %s", "full")` is same as `l.Printfl(WARN, "disk %s", "full")`
+// Unlike Printl(FATAL, ...), Fatal methods terminate the program after writing. (see SetExitFunc)
+// Panic methods flush the buffer after writing, then panic with the message.
+
+func (l *ALogger) Debug(a ...interface{}) {
+	l.print(DEBUG, a)
+}
+func (l *ALogger) Debugf(format string, a ...interface{}) {
+	l.printf(DEBUG, format, a)
+}
+func (l *ALogger) Debugj(addPrefix string, a interface{}) {
+	l.printj(DEBUG, addPrefix, a)
+}
+func (l *ALogger) Debugw(msg string, fields ...Field) {
+	l.printw(DEBUG, msg, fields)
+}
+func (l *ALogger) Info(a ...interface{}) {
+	l.print(INFO, a)
+}
+func (l *ALogger) Infof(format string, a ...interface{}) {
+	l.printf(INFO, format, a)
+}
+func (l *ALogger) Infoj(addPrefix string, a interface{}) {
+	l.printj(INFO, addPrefix, a)
+}
+func (l *ALogger) Infow(msg string, fields ...Field) {
+	l.printw(INFO, msg, fields)
+}
+func (l *ALogger) Warn(a ...interface{}) {
+	l.print(WARN, a)
+}
+func (l *ALogger) Warnf(format string, a ...interface{}) {
+	l.printf(WARN, format, a)
+}
+func (l *ALogger) Warnj(addPrefix string, a interface{}) {
+	l.printj(WARN, addPrefix, a)
+}
+func (l *ALogger) Warnw(msg string, fields ...Field) {
+	l.printw(WARN, msg, fields)
+}
+func (l *ALogger) Error(a ...interface{}) {
+	l.print(ERROR, a)
+}
+func (l *ALogger) Errorf(format string, a ...interface{}) {
+	l.printf(ERROR, format, a)
+}
+func (l *ALogger) Errorj(addPrefix string, a interface{}) {
+	l.printj(ERROR, addPrefix, a)
+}
+func (l *ALogger) Errorw(msg string, fields ...Field) {
+	l.printw(ERROR, msg, fields)
+}
+func (l *ALogger) Fatal(a ...interface{}) {
+	l.print(FATAL, a)
+	l.exit()
+}
+func (l *ALogger) Fatalf(format string, a ...interface{}) {
+	l.printf(FATAL, format, a)
+	l.exit()
+}
+func (l *ALogger) Fatalj(addPrefix string, a interface{}) {
+	l.printj(FATAL, addPrefix, a)
+	l.exit()
+}
+func (l *ALogger) Fatalw(msg string, fields ...Field) {
+	l.printw(FATAL, msg, fields)
+	l.exit()
+}
+func (l *ALogger) Panic(a ...interface{}) {
+	var b []byte
+	appendPrint(&b, a)
+	msg := string(b)
+	l.printw(PANIC, msg, nil)
+	l.panic(msg)
+}
+func (l *ALogger) Panicf(format string, a ...interface{}) {
+	var b []byte
+	appendPrintf(&b, format, a, l.fprec)
+	msg := string(b)
+	l.printw(PANIC, msg, nil)
+	l.panic(msg)
+}
+func (l *ALogger) Panicj(addPrefix string, a interface{}) {
+	l.printj(PANIC, addPrefix, a)
+	l.panic(l.jsonMsg(addPrefix, a))
+}
+func (l *ALogger) Panicw(msg string, fields ...Field) {
+	l.printw(PANIC, msg, fields)
+	l.panic(msg)
+}
+
+// panic flushes the buffer, and panics with msg. An entry should be written with printw before,
+// directly from the method called by a user, so F_CALLER finds the right frame.
+func (l *ALogger) panic(msg string) {
+	l.Flush()
+	panic(msg)
+}
@@ -0,0 +1,287 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// =====================================================================================================================
+// NETWORK WRITER
+// =====================================================================================================================
+
+// NetWriter is an io.WriteCloser sending to a TCP (optionally TLS) or UDP server, which reconnects
+// when the connection is lost. While disconnected, writes are kept in a spool (1MB by default)
+// and sent once reconnected; when the spool is full, the oldest writes are dropped and counted.
+// Reconnecting is done in the background with exponential backoff, so Write doesn't wait for it.
+//
+//	w := alog.NewNetWriter("tcp", "logs:5170", nil)
+//	l := alog.New(w, "", alog.F_JSON)
+//	defer l.Close()
+//
+// As a TCP write can succeed before the peer is found to be gone, a few writes right before
+// a disconnection can be lost without being counted.
+type NetWriter struct {
+	mu         sync.Mutex
+	network    string
+	addr       string
+	tlsConf    *tls.Config
+	timeout    time.Duration // dial and write timeout
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	conn       net.Conn
+
+	spool      []byte // writes while disconnected
+	spoolSizes []int  // size of each write in spool
+	spoolMax   int
+
+	dropped    uint64 // bytes
+	reconnects uint64
+	connected  bool // connected at least once
+	redialing  bool
+	closed     bool
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewNetWriter returns a writer sending to addr over network ("tcp", "udp", ...).
+// When conf isn't nil, TLS is used. It starts connecting in the background; writes until
+// connected are spooled.
+func NewNetWriter(network, addr string, conf *tls.Config) *NetWriter {
+	w := &NetWriter{
+		network:    network,
+		addr:       addr,
+		tlsConf:    conf,
+		timeout:    10 * time.Second,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+		spoolMax:   1 << 20,
+		quit:       make(chan struct{}),
+	}
+	w.mu.Lock()
+	w.redial()
+	w.mu.Unlock()
+	return w
+}
+
+// SetBackoff sets the wait between reconnect attempts; it starts at min and doubles up to max.
+func (w *NetWriter) SetBackoff(min, max time.Duration) {
+	if max < min {
+		max = min
+	}
+	w.mu.Lock()
+	w.minBackoff, w.maxBackoff = min, max
+	w.mu.Unlock()
+}
+
+// SetSpoolSize sets the max bytes kept while disconnected. When n <= 0, nothing is kept.
+func (w *NetWriter) SetSpoolSize(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	w.spoolMax = n
+	w.trimSpool(0)
+}
+
+// SetTimeout sets the dial and write timeout; 10 seconds by default. When d <= 0, there's no timeout.
+func (w *NetWriter) SetTimeout(d time.Duration) {
+	w.mu.Lock()
+	w.timeout = d
+	w.mu.Unlock()
+}
+
+// Dropped returns number of bytes dropped as the spool was full
+func (w *NetWriter) Dropped() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+// Reconnects returns number of times the connection was re-established after it was lost
+func (w *NetWriter) Reconnects() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reconnects
+}
+
+// Write sends p, or spools it while disconnected. It fails only after Close.
+func (w *NetWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.conn != nil && len(w.spoolSizes) == 0 {
+		if err = w.writeConn(p); err == nil {
+			return len(p), nil
+		}
+		w.disconnect()
+	}
+	w.addSpool(p)
+	w.redial()
+	return len(p), nil
+}
+
+// Close sends spooled writes if connected, and closes the connection.
+// Writes which couldn't be sent are counted as dropped.
+func (w *NetWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.quit)
+	w.mu.Unlock()
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if w.conn != nil {
+		err = w.flushSpool()
+		if cerr := w.conn.Close(); err == nil {
+			err = cerr
+		}
+		w.conn = nil
+	}
+	w.dropped += uint64(len(w.spool))
+	w.spool, w.spoolSizes = nil, nil
+	return err
+}
+
+// writeConn writes p to the connection. Caller must hold w.mu.
+func (w *NetWriter) writeConn(p []byte) error {
+	if w.timeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	}
+	n, err := w.conn.Write(p)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// disconnect closes a broken connection. Caller must hold w.mu.
+func (w *NetWriter) disconnect() {
+	w.conn.Close()
+	w.conn = nil
+}
+
+// addSpool keeps p, dropping the oldest writes if needed. Caller must hold w.mu.
+func (w *NetWriter) addSpool(p []byte) {
+	if len(p) > w.spoolMax {
+		w.dropped += uint64(len(p))
+		return
+	}
+	w.trimSpool(len(p))
+	w.spool = append(w.spool, p...)
+	w.spoolSizes = append(w.spoolSizes, len(p))
+}
+
+// trimSpool drops the oldest writes until n more bytes fit. Caller must hold w.mu.
+func (w *NetWriter) trimSpool(n int) {
+	var drop, i int
+	for ; i < len(w.spoolSizes) && len(w.spool)-drop+n > w.spoolMax; i++ {
+		drop += w.spoolSizes[i]
+	}
+	if i > 0 {
+		w.dropped += uint64(drop)
+		w.shiftSpool(i, drop)
+	}
+}
+
+// shiftSpool removes the first n writes of size bytes from the spool. Caller must hold w.mu.
+func (w *NetWriter) shiftSpool(n, size int) {
+	w.spool = w.spool[:copy(w.spool, w.spool[size:])]
+	w.spoolSizes = w.spoolSizes[:copy(w.spoolSizes, w.spoolSizes[n:])]
+}
+
+// flushSpool sends spooled writes in order. On error, unsent ones are kept. Caller must hold w.mu.
+func (w *NetWriter) flushSpool() error {
+	var off, i int
+	var err error
+	for ; i < len(w.spoolSizes); i++ {
+		size := w.spoolSizes[i]
+		if err = w.writeConn(w.spool[off : off+size]); err != nil {
+			break
+		}
+		off += size
+	}
+	w.shiftSpool(i, off)
+	return err
+}
+
+// redial starts reconnecting in the background unless it's already running. Caller must hold w.mu.
+func (w *NetWriter) redial() {
+	if w.redialing || w.closed {
+		return
+	}
+	w.redialing = true
+	w.wg.Add(1)
+	go w.redialLoop()
+}
+
+// redialLoop dials until connected and the spool is sent, waiting between attempts.
+func (w *NetWriter) redialLoop() {
+	defer w.wg.Done()
+	w.mu.Lock()
+	backoff := w.minBackoff
+	w.mu.Unlock()
+
+	for {
+		conn, err := w.dial()
+		w.mu.Lock()
+		if err == nil {
+			w.conn = conn
+			if w.connected {
+				w.reconnects++
+			}
+			w.connected = true
+			if err = w.flushSpool(); err == nil {
+				w.redialing = false
+				w.mu.Unlock()
+				return
+			}
+			w.disconnect()
+		}
+		if backoff < w.minBackoff {
+			backoff = w.minBackoff
+		} else if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+		w.mu.Unlock()
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-w.quit:
+			t.Stop()
+			w.mu.Lock()
+			w.redialing = false
+			w.mu.Unlock()
+			return
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+}
+
+// dial connects to the server
+func (w *NetWriter) dial() (net.Conn, error) {
+	w.mu.Lock()
+	d := net.Dialer{Timeout: w.timeout}
+	network, addr, conf := w.network, w.addr, w.tlsConf
+	w.mu.Unlock()
+
+	if conf != nil {
+		return tls.DialWithDialer(&d, network, addr, conf)
+	}
+	return d.Dial(network, addr)
+}
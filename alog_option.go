@@ -0,0 +1,84 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"io"
+	"time"
+)
+
+// =====================================================================================================================
+// A LOGGER / OPTION
+// =====================================================================================================================
+
+// Option configures ALogger created by NewWithOptions
+type Option func(l *ALogger)
+
+// NewWithOptions creates a logger with no prefix and no flag, then applies options in order.
+//
+//	l := alog.NewWithOptions(out, alog.WithFlag(alog.F_STD), alog.WithBufferSize(64<<10))
+func NewWithOptions(output io.Writer, opts ...Option) *ALogger {
+	l := New(output, "", 0)
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func WithPrefix(prefix string) Option {
+	return func(l *ALogger) { l.SetPrefix(prefix) }
+}
+
+// WithFlag sets the flag. F_USE_BUF_1K and F_USE_BUF_2K work the same as New,
+// unless WithBufferSize follows.
+func WithFlag(flag Format) Option {
+	return func(l *ALogger) {
+		l.SetFlag(flag)
+		if n := bufSizeOf(flag); n > 0 {
+			l.SetBufferSize(n)
+		}
+	}
+}
+
+// WithBufferSize uses a buffer of n bytes. See SetBufferSize.
+func WithBufferSize(n int) Option {
+	return func(l *ALogger) { l.SetBufferSize(n) }
+}
+
+// WithLevel enables only given levels. See LvOverride.
+func WithLevel(lvl Level) Option {
+	return func(l *ALogger) { l.LvOverride(lvl) }
+}
+
+func WithEncoder(enc Encoder) Option {
+	return func(l *ALogger) { l.SetEncoder(enc) }
+}
+
+func WithFloatPrecision(prec int) Option {
+	return func(l *ALogger) { l.SetFloatPrecision(prec) }
+}
+
+// WithAsync turns on async mode. See SetAsync.
+func WithAsync(size int, policy Overflow) Option {
+	return func(l *ALogger) { l.SetAsync(size, policy) }
+}
+
+// WithFlushInterval flushes the logger periodically. See SetFlushInterval.
+func WithFlushInterval(d time.Duration) Option {
+	return func(l *ALogger) { l.SetFlushInterval(d) }
+}
+
+// WithFlushLevel writes out the buffer immediately on given levels. See SetFlushLevel.
+func WithFlushLevel(lvl Level) Option {
+	return func(l *ALogger) { l.SetFlushLevel(lvl) }
+}
+
+// WithStackLevel adds a stack trace to entries of lvl or above. See SetStackLevel.
+func WithStackLevel(lvl Level) Option {
+	return func(l *ALogger) { l.SetStackLevel(lvl) }
+}
+
+// WithCallerSkip skips extra frames to find the caller. See SetCallerSkip.
+func WithCallerSkip(skip int) Option {
+	return func(l *ALogger) { l.SetCallerSkip(skip) }
+}
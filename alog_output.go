@@ -0,0 +1,216 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// =====================================================================================================================
+// A LOGGER / OUTPUTS
+// =====================================================================================================================
+
+// Output is an additional destination of a logger added by AddOutput.
+// It has its own level, format, buffer, and async queue, and shares the prefix with the logger.
+type Output struct {
+	sink
+	lvl Level
+}
+
+// AddOutput adds a destination which receives entries of lvl levels formatted by flag,
+// in addition to the logger's own output. eg. Send WARN and above to stderr as text, and everything to a file as JSON:
+//
+//	l := alog.New(f, "", alog.F_STD|alog.F_JSON)
+//	l.AddOutput(os.Stderr, alog.WARN|alog.ERROR|alog.FATAL|alog.PANIC, alog.F_STD|alog.F_LEVEL)
+//
+// Like the logger, unleveled calls such as Print are written regardless of lvl.
+// An error from one output doesn't stop others; use SetAsync of Output so a slow output doesn't block others.
+func (l *ALogger) AddOutput(w io.Writer, lvl Level, flag Format) *Output {
+	if w == nil {
+		w = Discard
+	}
+	o := &Output{sink: sink{c: l.core, flag: flag}, lvl: lvl}
+	o.setOutput(w)
+	o.setBufferSize(bufSizeOf(flag))
+
+	l.mu.Lock()
+	l.outputs = append(l.outputs, o)
+	atomic.StoreUint64((*uint64)(&l.outLvl), uint64(l.outLvl|lvl))
+	l.outFlag |= flag
+	l.mu.Unlock()
+	return o
+}
+
+// RemoveOutput flushes the output and removes it from the logger. The writer is not closed.
+func (l *ALogger) RemoveOutput(o *Output) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var lvl Level
+	var flag Format
+	outputs := l.outputs[:0]
+	for _, v := range l.outputs {
+		if v == o {
+			o.flush()
+			o.stopAsync()
+			continue
+		}
+		outputs = append(outputs, v)
+		lvl |= v.lvl
+		flag |= v.flag
+	}
+	for i := len(outputs); i < len(l.outputs); i++ {
+		l.outputs[i] = nil
+	}
+	l.outputs, l.outFlag = outputs, flag
+	atomic.StoreUint64((*uint64)(&l.outLvl), uint64(lvl))
+}
+
+// SetEncoder sets a custom encoder of the output. When nil, it's chosen by the output's flag.
+func (o *Output) SetEncoder(enc Encoder) {
+	o.c.mu.Lock()
+	o.enc = enc
+	o.c.encGen++
+	o.c.mu.Unlock()
+}
+
+// SetBufferSize sets the output's buffer size. See ALogger.SetBufferSize.
+func (o *Output) SetBufferSize(n int) {
+	o.c.mu.Lock()
+	o.flushBuf()
+	o.setBufferSize(n)
+	o.c.mu.Unlock()
+}
+
+// SetAsync writes to the output from a background goroutine. See ALogger.SetAsync.
+func (o *Output) SetAsync(size int, policy Overflow) {
+	o.c.mu.Lock()
+	o.setAsync(size, policy)
+	o.c.mu.Unlock()
+}
+
+// Dropped returns number of entries dropped by the output's async queue
+func (o *Output) Dropped() uint64 {
+	o.c.mu.Lock()
+	defer o.c.mu.Unlock()
+	return o.droppedCount()
+}
+
+// =====================================================================================================================
+// A LOGGER / SINK
+// =====================================================================================================================
+
+// sink is a writer with its format, buffer and async queue; the logger's own output and each Output.
+// Its methods are called while c.mu is held.
+type sink struct {
+	c            *core
+	out          io.Writer
+	levelOut     bool // out is a LevelWriter
+	flag         Format
+	enc          Encoder
+	hdr          Header
+	buf          []byte
+	bufUseBuffer bool
+	bufSize      int
+	async        *asyncQueue // nil unless SetAsync is used
+	dropped      uint64      // entries dropped by previous async queues
+}
+
+// setOutput changes the writer, after writing out the buffer and queued entries for the previous one.
+func (s *sink) setOutput(w io.Writer) {
+	if s.async != nil {
+		s.flushBuf()
+		s.async.setOutput(w)
+	}
+	s.out = w
+	_, s.levelOut = w.(LevelWriter)
+	s.setOutputUTC()
+}
+
+// setOutputUTC lets the writer use the same time zone as the log entries
+func (s *sink) setOutputUTC() {
+	if u, ok := s.out.(utcSetter); ok {
+		u.SetUTC(s.flag&F_UTC != 0)
+	}
+}
+
+// output formats an entry into the buffer, and writes it out when the buffer isn't used or is full.
+func (s *sink) output(lvl Level, t time.Time, c Caller, prefix []byte, msg []byte, p *preset, fields []Field) {
+	if !s.bufUseBuffer { // if buffer write is not used, reset buffer each time.
+		s.buf = s.buf[:0]
+	}
+	s.hdr.Flag, s.hdr.Time, s.hdr.Level, s.hdr.Prefix = timeFlag(s.flag, t), t, lvl, prefix
+	s.hdr.Caller = c
+	enc := encoderOf(s.enc, s.flag)
+	encodeEntry(&s.buf, enc, &s.hdr, msg, p.render(s, enc, s.c.encGen), fields)
+
+	if len(s.buf) > s.bufSize || lvl&s.c.flushLvl != 0 || s.levelOut {
+		s.write(lvl, s.buf)
+		s.buf = s.buf[:0]
+	}
+}
+
+// write writes p to the writer, or hands it to the async queue. lvl is the level of the entry
+// in p, or 0 when p has buffered entries.
+func (s *sink) write(lvl Level, p []byte) {
+	if s.async != nil {
+		s.async.push(lvl, p)
+		return
+	}
+	s.c.writeTo(s.out, lvl, p)
+}
+
+// setBufferSize resizes an empty buffer
+func (s *sink) setBufferSize(n int) {
+	if n <= 0 {
+		s.bufUseBuffer, s.bufSize = false, 0
+		return
+	}
+	if cap(s.buf) < n || cap(s.buf) > n*2 {
+		s.buf = make([]byte, 0, n)
+	}
+	s.bufUseBuffer, s.bufSize = true, n
+}
+
+// flushBuf writes out the buffer when used
+func (s *sink) flushBuf() {
+	if s.bufUseBuffer && len(s.buf) > 0 {
+		s.write(0, s.buf)
+		s.buf = s.buf[:0]
+	}
+}
+
+// flush writes out the buffer and drains the async queue
+func (s *sink) flush() {
+	s.flushBuf()
+	if s.async != nil {
+		s.async.drain()
+	}
+}
+
+// setAsync replaces the async queue by a new one of size entries, or none when size <= 0.
+func (s *sink) setAsync(size int, policy Overflow) {
+	s.flushBuf()
+	s.stopAsync()
+	if size > 0 {
+		s.async = newAsyncQueue(s.out, size, policy, s.c.writeTo)
+	}
+}
+
+// stopAsync stops the async queue if any
+func (s *sink) stopAsync() {
+	if s.async != nil {
+		s.dropped += s.async.stop()
+		s.async = nil
+	}
+}
+
+// droppedCount returns number of entries dropped by async queues
+func (s *sink) droppedCount() uint64 {
+	if s.async == nil {
+		return s.dropped
+	}
+	return s.dropped + s.async.droppedCount()
+}
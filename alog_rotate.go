@@ -0,0 +1,570 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =====================================================================================================================
+// ROTATING FILE
+// =====================================================================================================================
+
+// RotateEvery is a period of time-based rotation
+type RotateEvery uint8
+
+const (
+	ROTATE_HOURLY RotateEvery = iota + 1
+	ROTATE_DAILY
+)
+
+// start returns the start of the period t belongs to
+func (e RotateEvery) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch e {
+	case ROTATE_HOURLY:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case ROTATE_DAILY:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// RotatingFile is an io.WriteCloser that rotates a file when its size exceeds maxBytes,
+// and optionally at hour or day boundaries (see NewTimedRotatingFile).
+// A file rotated by size is renamed with a numeric suffix (`app.log.1` being the newest) by default,
+// or a timestamp suffix with SetTimeSuffix. Only maxBackups rotated files are kept.
+//
+// Rotation only happens between Write calls. As ALogger writes whole entries (or a whole
+// buffer of entries with F_USE_BUF_*) in a single Write, an entry is never split across files.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string // current file
+	maxBytes   int64
+	maxBackups int
+	timeSuffix string // time layout of a backup suffix; numeric when empty
+	file       *os.File
+	size       int64
+	closed     bool
+
+	// time-based rotation
+	pattern string // strftime-style file name; empty when rotated by size only
+	every   RotateEvery
+	period  time.Time // start of the current period
+	utc     bool
+	clock   func() time.Time
+
+	// compression
+	compress bool
+	wg       sync.WaitGroup
+	gzMu     sync.Mutex
+	gzErr    error // first error from background compression
+}
+
+// NewRotatingFile opens (or creates) the file at path for appending.
+// When maxBytes <= 0, the file won't be rotated by size.
+func NewRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+		clock:      time.Now,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewTimedRotatingFile creates a file named by a strftime-style pattern such as `./log/app-%Y-%m-%d.log`,
+// and moves on to a new file at every hour or day boundary. Supported directives are
+// %Y, %y, %m, %d, %H, %M, %S and %%. Only maxBackups files of previous periods are kept,
+// and a file can also be rotated by size within a period when maxBytes > 0.
+// The file is opened at the first write, so SetClock and SetUTC can be set before that.
+func NewTimedRotatingFile(pattern string, every RotateEvery, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	if every != ROTATE_HOURLY && every != ROTATE_DAILY {
+		return nil, errors.New("alog: unknown rotation period")
+	}
+	if !strings.Contains(pattern, "%") {
+		return nil, errors.New("alog: pattern has no time directive")
+	}
+	return &RotatingFile{
+		pattern:    filepath.Clean(pattern),
+		every:      every,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+		clock:      time.Now,
+	}, nil
+}
+
+// SetTimeSuffix makes files rotated by size named with a time suffix such as `app.log.20201018-150405.000`
+// instead of a number. The layout should sort in time order as a string.
+func (r *RotatingFile) SetTimeSuffix(layout string) {
+	r.mu.Lock()
+	r.timeSuffix = layout
+	r.mu.Unlock()
+}
+
+// SetUTC uses UTC for file names and period boundaries.
+// ALogger calls this with its F_UTC flag when RotatingFile is its output.
+func (r *RotatingFile) SetUTC(utc bool) {
+	r.mu.Lock()
+	r.utc = utc
+	r.mu.Unlock()
+}
+
+// SetClock replaces time.Now used to decide rotation; mainly for testing.
+func (r *RotatingFile) SetClock(fn func() time.Time) {
+	if fn == nil {
+		fn = time.Now
+	}
+	r.mu.Lock()
+	r.clock = fn
+	r.mu.Unlock()
+}
+
+// SetCompress gzips rotated files in the background. A rotated file `app.log.1` becomes `app.log.1.gz`.
+func (r *RotatingFile) SetCompress(compress bool) {
+	r.mu.Lock()
+	r.compress = compress
+	r.mu.Unlock()
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.pattern != "" {
+		if err := r.checkPeriod(); err != nil {
+			return 0, err
+		}
+	}
+	if r.file == nil { // previous rotation failed to open a file
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file regardless of its size.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	if r.path == "" { // timed file not opened yet
+		return nil
+	}
+	return r.rotate()
+}
+
+// Close closes the file and waits for background compression to finish.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.wg.Wait()
+	if err == nil {
+		r.gzMu.Lock()
+		err = r.gzErr
+		r.gzMu.Unlock()
+	}
+	return err
+}
+
+// now returns the current time from the clock. Caller must hold r.mu.
+func (r *RotatingFile) now() time.Time {
+	if r.utc {
+		return r.clock().UTC()
+	}
+	return r.clock()
+}
+
+// open opens the file for appending. Caller must hold r.mu.
+func (r *RotatingFile) open() error {
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size = f, fi.Size()
+	return nil
+}
+
+// checkPeriod moves on to a file of the current period when the period has changed.
+// Caller must hold r.mu.
+func (r *RotatingFile) checkPeriod() error {
+	t := r.now()
+	p := r.every.start(t)
+	if r.path != "" && p.Equal(r.period) {
+		return nil
+	}
+
+	prev := r.path
+	var name []byte
+	appendStrftime(&name, r.pattern, t)
+	r.path, r.period = string(name), p
+	if prev == r.path {
+		return nil
+	}
+
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+		r.file = nil
+	}
+	if prev != "" {
+		r.wg.Wait()
+		if err := r.prunePeriods(); err != nil {
+			return err
+		}
+		if _, err := os.Stat(prev); err == nil && r.compress { // prev is kept as a backup
+			r.compressBg(prev)
+		}
+	}
+	return r.open()
+}
+
+// rotate closes the current file, renames it to a backup, prunes old backups,
+// and opens a new file. Caller must hold r.mu.
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+		r.file = nil
+	}
+	r.wg.Wait() // don't rename or remove files being compressed
+
+	if r.timeSuffix == "" {
+		if err := r.rotateNumeric(); err != nil {
+			return err
+		}
+	} else {
+		if err := r.rotateTime(r.now()); err != nil {
+			return err
+		}
+	}
+	return r.open()
+}
+
+// rotateNumeric shifts `path.N` to `path.N+1` and renames the file to `path.1`.
+func (r *RotatingFile) rotateNumeric() error {
+	if r.maxBackups <= 0 {
+		return removeIfExists(r.path)
+	}
+	last := r.path + "." + strconv.Itoa(r.maxBackups)
+	if err := removeIfExists(last); err != nil {
+		return err
+	}
+	if err := removeIfExists(last + ".gz"); err != nil {
+		return err
+	}
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		from, to := r.path+"."+strconv.Itoa(i), r.path+"."+strconv.Itoa(i+1)
+		if err := renameIfExists(from, to); err != nil {
+			return err
+		}
+		if err := renameIfExists(from+".gz", to+".gz"); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if r.compress {
+		r.compressBg(r.path + ".1")
+	}
+	return nil
+}
+
+// rotateTime renames the file to `path.<time>` and removes the oldest backups over maxBackups.
+func (r *RotatingFile) rotateTime(t time.Time) error {
+	if r.maxBackups <= 0 {
+		return removeIfExists(r.path)
+	}
+	backups, err := r.timeBackups()
+	if err != nil {
+		return err
+	}
+	ts := t.Format(r.timeSuffix)
+	to := r.path + "." + ts
+	if n := len(backups); n > 0 && backups[n-1].ts == ts { // rotated more than once within the layout's resolution
+		to += "." + strconv.Itoa(backups[n-1].n+1)
+	}
+	if err := os.Rename(r.path, to); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if r.compress {
+		r.compressBg(to)
+	}
+
+	for i := 0; i < len(backups)+1-r.maxBackups; i++ {
+		if err := removeIfExists(backups[i].name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type timeBackup struct {
+	name string
+	ts   string // time suffix
+	n    int    // counter after the time suffix
+}
+
+// timeBackups returns backup files created by rotateTime, oldest first.
+func (r *RotatingFile) timeBackups() ([]timeBackup, error) {
+	matches, err := filepath.Glob(globEscape(r.path) + ".*")
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(r.path) + "."
+	var backups []timeBackup
+	for _, m := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), base), ".gz")
+		if r.isTimeSuffix(suffix) {
+			backups = append(backups, timeBackup{name: m, ts: suffix})
+			continue
+		}
+		if i := strings.LastIndexByte(suffix, '.'); i > 0 && r.isTimeSuffix(suffix[:i]) { // trailing counter from rotateTime
+			if n, err := strconv.Atoi(suffix[i+1:]); err == nil && n > 0 {
+				backups = append(backups, timeBackup{name: m, ts: suffix[:i], n: n})
+			}
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].ts != backups[j].ts {
+			return backups[i].ts < backups[j].ts
+		}
+		return backups[i].n < backups[j].n
+	})
+	return backups, nil
+}
+
+// isTimeSuffix checks if s is formatted exactly with the time suffix layout.
+// (time.Parse alone would accept a trailing `.N` as fractional seconds.)
+func (r *RotatingFile) isTimeSuffix(s string) bool {
+	t, err := time.Parse(r.timeSuffix, s)
+	return err == nil && t.Format(r.timeSuffix) == s
+}
+
+// prunePeriods removes files of the oldest periods, keeping maxBackups periods
+// other than the current one. Backups rotated by size within a period
+// (`app-2020-10-18.log.1`, `.gz`) are removed along with their period.
+func (r *RotatingFile) prunePeriods() error {
+	glob := strftimeGlob(r.pattern)
+	matches, err := filepath.Glob(glob)
+	if err != nil {
+		return err
+	}
+	more, err := filepath.Glob(glob + ".*")
+	if err != nil {
+		return err
+	}
+	matches = append(matches, more...)
+
+	// every directive has a fixed width, so any file name from the pattern has the same length
+	files := make(map[string][]string)
+	var periods []string
+	for _, m := range matches {
+		if len(m) < len(r.path) {
+			continue
+		}
+		key := m[:len(r.path)]
+		if key == r.path {
+			continue
+		}
+		if _, ok := files[key]; !ok {
+			periods = append(periods, key)
+		}
+		files[key] = append(files[key], m)
+	}
+	sort.Strings(periods)
+
+	for i := 0; i < len(periods)-r.maxBackups; i++ {
+		for _, f := range files[periods[i]] {
+			if err := removeIfExists(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compressBg gzips a file in the background. Caller must hold r.mu.
+func (r *RotatingFile) compressBg(path string) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := gzipFile(path); err != nil {
+			r.gzMu.Lock()
+			if r.gzErr == nil {
+				r.gzErr = err
+			}
+			r.gzMu.Unlock()
+		}
+	}()
+}
+
+// gzipFile compresses path into `path.gz` and removes path.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		src.Close()
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	src.Close()
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// appendStrftime appends t formatted by a strftime-style pattern.
+// Unknown directives are appended as is.
+func appendStrftime(dst *[]byte, pattern string, t time.Time) {
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			*dst = append(*dst, pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			itoa(dst, t.Year(), 4)
+		case 'y':
+			itoa(dst, t.Year()%100, 2)
+		case 'm':
+			itoa(dst, int(t.Month()), 2)
+		case 'd':
+			itoa(dst, t.Day(), 2)
+		case 'H':
+			itoa(dst, t.Hour(), 2)
+		case 'M':
+			itoa(dst, t.Minute(), 2)
+		case 'S':
+			itoa(dst, t.Second(), 2)
+		case '%':
+			*dst = append(*dst, '%')
+		default:
+			*dst = append(*dst, '%', pattern[i])
+		}
+	}
+}
+
+// strftimeGlob converts a strftime-style pattern to a glob matching its file names
+func strftimeGlob(pattern string) string {
+	const d2 = "[0-9][0-9]"
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			b.WriteString(globEscape(pattern[i : i+1]))
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			b.WriteString(d2 + d2)
+		case 'y', 'm', 'd', 'H', 'M', 'S':
+			b.WriteString(d2)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteString(globEscape(pattern[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func renameIfExists(from, to string) error {
+	if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// globEscape escapes glob meta characters in a path
+func globEscape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
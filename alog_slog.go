@@ -0,0 +1,138 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+//go:build go1.21
+
+package alog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// =====================================================================================================================
+// SLOG HANDLER
+// =====================================================================================================================
+
+// SlogHandler is a slog.Handler writing through ALogger, so records are formatted by the logger's
+// encoder (text, F_JSON, F_LOGFMT, ...) into its buffer under its lock.
+// Attributes in a group are written with dotted keys such as `group.key`.
+//
+//	log := slog.New(alog.NewSlogHandler(l))
+type SlogHandler struct {
+	l     *ALogger
+	group string // prefix of keys from WithGroup such as "g1.g2."
+}
+
+// NewSlogHandler returns a slog.Handler backed by l.
+// slog levels are mapped to DEBUG (< Info), INFO (< Warn), WARN (< Error), and ERROR.
+func NewSlogHandler(l *ALogger) *SlogHandler {
+	return &SlogHandler{l: l}
+}
+
+// slogLevel maps a slog level to an alog level
+func slogLevel(lvl slog.Level) Level {
+	switch {
+	case lvl < slog.LevelInfo:
+		return DEBUG
+	case lvl < slog.LevelWarn:
+		return INFO
+	case lvl < slog.LevelError:
+		return WARN
+	}
+	return ERROR
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return h.l.LvIsEnabled(slogLevel(lvl))
+}
+
+// Handle writes the record. A zero Record.Time is not written.
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	lvl := slogLevel(r.Level)
+	l := h.l
+	if (l.lvl|l.outLevels())&lvl == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ctxFields = l.ctxFields[:0]
+	r.Attrs(func(a slog.Attr) bool {
+		l.ctxFields = appendSlogAttr(l.ctxFields, h.group, a)
+		return true
+	})
+	var pc uintptr
+	if (l.flag|l.outFlag)&callerFlags != 0 {
+		pc = r.PC
+	}
+	l.msg = append(l.msg[:0], r.Message...)
+	l.output(lvl, r.Time, pc, l.msg, l.ctxFields)
+	return nil
+}
+
+// WithAttrs returns a handler with a child logger (see ALogger.With) having attrs as fields.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []Field
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, h.group, a)
+	}
+	if len(fields) == 0 {
+		return h
+	}
+	return &SlogHandler{l: h.l.With(fields...), group: h.group}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{l: h.l, group: h.group + name + "."}
+}
+
+// appendSlogAttr appends an attribute as fields; a group is flattened with dotted keys.
+func appendSlogAttr(dst []Field, group string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return dst
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return dst
+		}
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range attrs {
+			dst = appendSlogAttr(dst, group, ga)
+		}
+		return dst
+	}
+
+	key := a.Key
+	if group != "" {
+		key = group + a.Key
+	}
+	switch v := a.Value; v.Kind() {
+	case slog.KindString:
+		return append(dst, Str(key, v.String()))
+	case slog.KindInt64:
+		return append(dst, Int64(key, v.Int64()))
+	case slog.KindUint64:
+		return append(dst, Uint(key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(dst, Float(key, v.Float64()))
+	case slog.KindBool:
+		return append(dst, Bool(key, v.Bool()))
+	case slog.KindDuration:
+		return append(dst, Int64(key, int64(v.Duration())))
+	case slog.KindTime:
+		return append(dst, Str(key, v.Time().Format(time.RFC3339Nano)))
+	}
+	if err, ok := a.Value.Any().(error); ok {
+		return append(dst, Field{Key: key, Type: FieldError, Iface: err})
+	}
+	return append(dst, Str(key, fmt.Sprintf("%+v", a.Value.Any())))
+}
@@ -0,0 +1,87 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+//go:build go1.21
+
+package alog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gonyyi/alog"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/slogtest"
+)
+
+func Test_ALog_SlogHandler(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", alog.F_TIME|alog.F_JSON)
+	if err := slogtest.TestHandler(alog.NewSlogHandler(l), func() []map[string]any {
+		var ms []map[string]any
+		for _, line := range bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n")) {
+			var flat map[string]any
+			if err := json.Unmarshal(line, &flat); err != nil {
+				t.Fatalf("unexpected: exp=<JSON>; act=<%s>", line)
+			}
+			ms = append(ms, nestKeys(flat))
+		}
+		return ms
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+// nestKeys turns dotted keys such as `g.k` into nested maps
+func nestKeys(flat map[string]any) map[string]any {
+	m := make(map[string]any)
+	for k, v := range flat {
+		cur := m
+		keys := strings.Split(k, ".")
+		for _, g := range keys[:len(keys)-1] {
+			sub, ok := cur[g].(map[string]any)
+			if !ok {
+				sub = make(map[string]any)
+				cur[g] = sub
+			}
+			cur = sub
+		}
+		cur[keys[len(keys)-1]] = v
+	}
+	return m
+}
+
+func Test_ALog_SlogHandler_Text(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", alog.F_LEVEL)
+	log := slog.New(alog.NewSlogHandler(l)).With("request_id", "r1").WithGroup("http")
+
+	log.Debug("debug") // DEBUG is disabled by default
+	log.Info("done", "status", 200, slog.Group("req", "method", "GET"))
+	log.Log(context.Background(), slog.LevelWarn+1, "slow")
+
+	exp := "[INF] done request_id=r1 http.status=200 http.req.method=GET\n" +
+		"[WRN] slow request_id=r1\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+
+	b.Reset()
+	l.SetFlag(alog.F_CALLER)
+	_, _, line, _ := runtime.Caller(0)
+	log.Info("caller") // slog's caller is used
+	if exp := fmt.Sprintf("alog_slog_test.go:%d: caller request_id=r1\n", line+1); b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+
+	b.Reset()
+	l.SetFlag(0)
+	l.SetStackLevel(alog.WARN)
+	log.Warn("stack") // frames of log/slog are trimmed
+	if exp := "stack request_id=r1\n\talog_test.Test_ALog_SlogHandler_Text\n"; !strings.HasPrefix(b.String(), exp) {
+		t.Fatalf("unexpected: exp=<%s...>; act=<%s>", exp, b.String())
+	}
+}
@@ -3,8 +3,10 @@
 package alog
 
 import (
+	"context"
 	"io"
 	"os"
+	"time"
 )
 
 // =====================================================================================================================
@@ -12,14 +14,25 @@ import (
 // =====================================================================================================================
 var std = New(os.Stdout, "", F_STD)
 
+// Functions below call print methods of std directly rather than its exported methods,
+// so F_CALLER finds the caller at the same depth as ALogger methods.
 func Printf(format string, s ...interface{}) {
-	std.Printf(format, s...)
+	std.printf(0, format, s)
 }
 func Printj(optionalPrefix string, a interface{}) {
-	std.Printj(optionalPrefix, a)
+	std.printj(0, optionalPrefix, a)
 }
 func Print(s ...interface{}) {
-	std.Print(s...)
+	std.print(0, s)
+}
+func Printw(msg string, fields ...Field) {
+	std.printw(0, msg, fields)
+}
+
+// Default returns the standard logger used by package-level functions,
+// eg. `alog.Default().With(...)` for a child logger.
+func Default() *ALogger {
+	return std
 }
 func SetOutput(output io.Writer) {
 	std.SetOutput(output)
@@ -30,3 +43,169 @@ func SetPrefix(prefix string) {
 func SetFlag(flag Format) {
 	std.SetFlag(flag)
 }
+func SetEncoder(enc Encoder) {
+	std.SetEncoder(enc)
+}
+func SetFloatPrecision(prec int) {
+	std.SetFloatPrecision(prec)
+}
+func SetCallerSkip(skip int) {
+	std.SetCallerSkip(skip)
+}
+func SetStackLevel(lvl Level) {
+	std.SetStackLevel(lvl)
+}
+func SetBufferSize(n int) {
+	std.SetBufferSize(n)
+}
+func SetAsync(size int, policy Overflow) {
+	std.SetAsync(size, policy)
+}
+func Dropped() uint64 {
+	return std.Dropped()
+}
+func Flush() {
+	std.Flush()
+}
+func SetFlushInterval(d time.Duration) {
+	std.SetFlushInterval(d)
+}
+func SetFlushLevel(lvl Level) {
+	std.SetFlushLevel(lvl)
+}
+func SetExitFunc(fn func(code int)) {
+	std.SetExitFunc(fn)
+}
+func AddExitHook(fn func()) {
+	std.AddExitHook(fn)
+}
+func LvEnable(lvl Level) {
+	std.LvEnable(lvl)
+}
+func LvDisable(lvl Level) {
+	std.LvDisable(lvl)
+}
+func LvOverride(lvl Level) {
+	std.LvOverride(lvl)
+}
+func LvIsEnabled(lvl Level) bool {
+	return std.LvIsEnabled(lvl)
+}
+
+// =====================================================================================================================
+// ALOG STD / LEVEL
+// =====================================================================================================================
+func Debug(a ...interface{}) {
+	std.print(DEBUG, a)
+}
+func Debugf(format string, a ...interface{}) {
+	std.printf(DEBUG, format, a)
+}
+func Debugj(addPrefix string, a interface{}) {
+	std.printj(DEBUG, addPrefix, a)
+}
+func Debugw(msg string, fields ...Field) {
+	std.printw(DEBUG, msg, fields)
+}
+func Info(a ...interface{}) {
+	std.print(INFO, a)
+}
+func Infof(format string, a ...interface{}) {
+	std.printf(INFO, format, a)
+}
+func Infoj(addPrefix string, a interface{}) {
+	std.printj(INFO, addPrefix, a)
+}
+func Infow(msg string, fields ...Field) {
+	std.printw(INFO, msg, fields)
+}
+func Warn(a ...interface{}) {
+	std.print(WARN, a)
+}
+func Warnf(format string, a ...interface{}) {
+	std.printf(WARN, format, a)
+}
+func Warnj(addPrefix string, a interface{}) {
+	std.printj(WARN, addPrefix, a)
+}
+func Warnw(msg string, fields ...Field) {
+	std.printw(WARN, msg, fields)
+}
+func Error(a ...interface{}) {
+	std.print(ERROR, a)
+}
+func Errorf(format string, a ...interface{}) {
+	std.printf(ERROR, format, a)
+}
+func Errorj(addPrefix string, a interface{}) {
+	std.printj(ERROR, addPrefix, a)
+}
+func Errorw(msg string, fields ...Field) {
+	std.printw(ERROR, msg, fields)
+}
+func Fatal(a ...interface{}) {
+	std.print(FATAL, a)
+	std.exit()
+}
+func Fatalf(format string, a ...interface{}) {
+	std.printf(FATAL, format, a)
+	std.exit()
+}
+func Fatalj(addPrefix string, a interface{}) {
+	std.printj(FATAL, addPrefix, a)
+	std.exit()
+}
+func Fatalw(msg string, fields ...Field) {
+	std.printw(FATAL, msg, fields)
+	std.exit()
+}
+func Panic(a ...interface{}) {
+	var b []byte
+	appendPrint(&b, a)
+	msg := string(b)
+	std.printw(PANIC, msg, nil)
+	std.panic(msg)
+}
+func Panicf(format string, a ...interface{}) {
+	var b []byte
+	appendPrintf(&b, format, a, std.fprec)
+	msg := string(b)
+	std.printw(PANIC, msg, nil)
+	std.panic(msg)
+}
+func Panicj(addPrefix string, a interface{}) {
+	std.printj(PANIC, addPrefix, a)
+	std.panic(std.jsonMsg(addPrefix, a))
+}
+func Panicw(msg string, fields ...Field) {
+	std.printw(PANIC, msg, fields)
+	std.panic(msg)
+}
+
+func AddContextHook(fn ContextHook) {
+	std.AddContextHook(fn)
+}
+
+// `*Ctx` functions use a logger from NewContext, or the standard logger.
+func DebugCtx(ctx context.Context, msg string, fields ...Field) {
+	loggerOf(ctx).printCtx(ctx, DEBUG, msg, fields)
+}
+func InfoCtx(ctx context.Context, msg string, fields ...Field) {
+	loggerOf(ctx).printCtx(ctx, INFO, msg, fields)
+}
+func WarnCtx(ctx context.Context, msg string, fields ...Field) {
+	loggerOf(ctx).printCtx(ctx, WARN, msg, fields)
+}
+func ErrorCtx(ctx context.Context, msg string, fields ...Field) {
+	loggerOf(ctx).printCtx(ctx, ERROR, msg, fields)
+}
+func FatalCtx(ctx context.Context, msg string, fields ...Field) {
+	l := loggerOf(ctx)
+	l.printCtx(ctx, FATAL, msg, fields)
+	l.exit()
+}
+func PanicCtx(ctx context.Context, msg string, fields ...Field) {
+	l := loggerOf(ctx)
+	l.printCtx(ctx, PANIC, msg, fields)
+	l.panic(msg)
+}
@@ -0,0 +1,317 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// =====================================================================================================================
+// SYSLOG
+// =====================================================================================================================
+
+// Facility is a syslog facility
+type Facility uint8
+
+const (
+	FACILITY_KERN Facility = iota
+	FACILITY_USER
+	FACILITY_MAIL
+	FACILITY_DAEMON
+	FACILITY_AUTH
+	FACILITY_SYSLOG
+	FACILITY_LPR
+	FACILITY_NEWS
+	FACILITY_UUCP
+	FACILITY_CRON
+	FACILITY_AUTHPRIV
+	FACILITY_FTP
+	FACILITY_LOCAL0 Facility = iota + 4
+	FACILITY_LOCAL1
+	FACILITY_LOCAL2
+	FACILITY_LOCAL3
+	FACILITY_LOCAL4
+	FACILITY_LOCAL5
+	FACILITY_LOCAL6
+	FACILITY_LOCAL7
+)
+
+// SyslogFormat is a format of syslog messages
+type SyslogFormat uint8
+
+const (
+	SYSLOG_RFC5424 SyslogFormat = iota // <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID - - MSG
+	SYSLOG_RFC3164                     // <PRI>Mmm dd hh:mm:ss HOSTNAME APP-NAME[PID]: MSG
+)
+
+// syslogSeverity maps a level to a syslog severity. Unleveled entries are notice (5).
+func syslogSeverity(lvl Level) int {
+	switch lvl {
+	case DEBUG:
+		return 7
+	case INFO:
+		return 6
+	case WARN:
+		return 4
+	case ERROR:
+		return 3
+	case FATAL:
+		return 2
+	case PANIC:
+		return 1
+	}
+	return 5
+}
+
+// syslogSockets are paths of a local syslog daemon
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogWriter is an output sending each entry as a syslog message, with the severity mapped
+// from the entry's level: DEBUG=debug, INFO=info, WARN=warning, ERROR=err, FATAL=crit, PANIC=alert,
+// and notice for unleveled calls.
+//
+//	w, err := alog.NewSyslogWriter("", "", alog.FACILITY_LOCAL0, "myapp")
+//	l := alog.New(w, "", alog.F_LEVEL)
+//
+// As the syslog message has its own timestamp, a flag without F_TIME is usually used.
+// Messages are sent as datagrams over "unixgram" and "udp", with octet-counting framing (RFC 6587)
+// over "tcp", and terminated by a newline over a "unix" stream socket as log/syslog does.
+// When sending fails, it reconnects once and retries.
+type SyslogWriter struct {
+	mu       sync.Mutex
+	network  string
+	addr     string
+	conn     net.Conn
+	framing  syslogFraming
+	facility Facility
+	format   SyslogFormat
+	hostname string
+	appName  string
+	pid      string
+	clock    func() time.Time
+	buf      []byte
+	closed   bool
+}
+
+// NewSyslogWriter connects to a syslog server at addr, eg. ("udp", "logs:514") or ("tcp", "logs:601").
+// When network and addr are empty, it connects to the local syslog daemon (/dev/log).
+// When appName is empty, the program name is used.
+func NewSyslogWriter(network, addr string, facility Facility, appName string) (*SyslogWriter, error) {
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+	hostname, _ := os.Hostname()
+	w := &SyslogWriter{
+		network:  network,
+		addr:     addr,
+		facility: facility,
+		hostname: hostname,
+		appName:  appName,
+		pid:      strconv.Itoa(os.Getpid()),
+		clock:    time.Now,
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// SetFormat sets the message format; SYSLOG_RFC5424 by default.
+// Older local daemons may need SYSLOG_RFC3164.
+func (w *SyslogWriter) SetFormat(f SyslogFormat) {
+	w.mu.Lock()
+	w.format = f
+	w.mu.Unlock()
+}
+
+// SetFacility sets the facility
+func (w *SyslogWriter) SetFacility(f Facility) {
+	w.mu.Lock()
+	w.facility = f
+	w.mu.Unlock()
+}
+
+// SetHostname sets the hostname of messages; os.Hostname() by default.
+func (w *SyslogWriter) SetHostname(hostname string) {
+	w.mu.Lock()
+	w.hostname = hostname
+	w.mu.Unlock()
+}
+
+// SetAppName sets the app name of messages
+func (w *SyslogWriter) SetAppName(appName string) {
+	w.mu.Lock()
+	w.appName = appName
+	w.mu.Unlock()
+}
+
+// SetClock sets a function returning the time of messages, mainly for testing.
+func (w *SyslogWriter) SetClock(fn func() time.Time) {
+	w.mu.Lock()
+	if fn == nil {
+		fn = time.Now
+	}
+	w.clock = fn
+	w.mu.Unlock()
+}
+
+// Write sends p as a message of notice severity
+func (w *SyslogWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(0, p)
+}
+
+// WriteLevel sends p as a message with the severity of lvl. A trailing newline is removed,
+// and a multi-line entry is sent as a single message.
+func (w *SyslogWriter) WriteLevel(lvl Level, p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	msg := p
+	if len(msg) > 0 && msg[len(msg)-1] == '\n' {
+		msg = msg[:len(msg)-1]
+	}
+	w.buf = w.appendMessage(w.buf[:0], lvl, msg)
+
+	if w.conn != nil {
+		if _, err = w.conn.Write(w.buf); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	if err = w.connect(); err != nil {
+		return 0, err
+	}
+	if _, err = w.conn.Write(w.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// connect (re)connects to the server. Caller must hold w.mu unless in the constructor.
+func (w *SyslogWriter) connect() error {
+	if w.network != "" || w.addr != "" {
+		c, err := net.Dial(w.network, w.addr)
+		if err != nil {
+			return err
+		}
+		w.conn, w.framing = c, syslogFramingOf(w.network)
+		return nil
+	}
+	for _, path := range syslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if c, err := net.Dial(network, path); err == nil {
+				w.conn, w.framing = c, syslogFramingOf(network)
+				return nil
+			}
+		}
+	}
+	return errors.New("alog: local syslog daemon not found")
+}
+
+// syslogFraming is how messages are separated on a stream connection
+type syslogFraming uint8
+
+const (
+	syslogNoFraming  syslogFraming = iota // datagrams
+	syslogOctetCount                      // "LEN SP MSG" (RFC 6587)
+	syslogNewline                         // "MSG LF"; a local daemon over a unix stream socket
+)
+
+// syslogFramingOf returns the framing for the network
+func syslogFramingOf(network string) syslogFraming {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return syslogOctetCount
+	case "unix":
+		return syslogNewline
+	}
+	return syslogNoFraming
+}
+
+// appendMessage appends a message including the framing. Caller must hold w.mu.
+func (w *SyslogWriter) appendMessage(dst []byte, lvl Level, msg []byte) []byte {
+	t := w.clock()
+	start := len(dst)
+
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(w.facility)*8+int64(syslogSeverity(lvl)), 10)
+	dst = append(dst, '>')
+
+	if w.format == SYSLOG_RFC3164 {
+		dst = t.AppendFormat(dst, time.Stamp)
+		if w.hostname != "" {
+			dst = append(dst, ' ')
+			dst = append(dst, w.hostname...)
+		}
+		dst = append(dst, ' ')
+		dst = append(dst, w.appName...)
+		dst = append(dst, '[')
+		dst = append(dst, w.pid...)
+		dst = append(dst, "]: "...)
+	} else {
+		dst = append(dst, "1 "...)
+		dst = t.AppendFormat(dst, "2006-01-02T15:04:05.000000Z07:00")
+		dst = append(dst, ' ')
+		dst = appendSyslogHeader(dst, w.hostname)
+		dst = append(dst, ' ')
+		dst = appendSyslogHeader(dst, w.appName)
+		dst = append(dst, ' ')
+		dst = append(dst, w.pid...)
+		dst = append(dst, " - - "...)
+	}
+	dst = append(dst, msg...)
+
+	switch w.framing {
+	case syslogNoFraming:
+		return dst
+	case syslogNewline:
+		return append(dst, '\n')
+	}
+	// octet-counting: "LEN SP MSG"; shift the message to put its length before it.
+	n := len(dst) - start
+	var lenBuf [24]byte
+	ls := strconv.AppendInt(lenBuf[:0], int64(n), 10)
+	ls = append(ls, ' ')
+	dst = append(dst, ls...)
+	copy(dst[start+len(ls):], dst[start:start+n])
+	copy(dst[start:], ls)
+	return dst
+}
+
+// appendSyslogHeader appends a RFC 5424 header field; NILVALUE ("-") when empty.
+func appendSyslogHeader(dst []byte, s string) []byte {
+	if s == "" {
+		return append(dst, '-')
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c > 32 && c < 127 {
+			dst = append(dst, c)
+		} else {
+			dst = append(dst, '_')
+		}
+	}
+	return dst
+}
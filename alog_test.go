@@ -4,11 +4,27 @@ package alog_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gonyyi/alog"
+	"io"
 	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 /*
@@ -57,6 +73,10 @@ import (
 // =====================================================================================================================
 // TEST
 // =====================================================================================================================
+func TestMain(m *testing.M) {
+	os.MkdirAll("./tmp", 0755) // tests and benchmarks write their output here
+	os.Exit(m.Run())
+}
 func Test_ALog(t *testing.T) {
 	var b bytes.Buffer
 	l := alog.New(&b, "", 0)
@@ -132,6 +152,348 @@ func Test_ALog_Level(t *testing.T) {
 		}
 	}
 }
+func Test_ALog_Level_Methods(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", alog.F_LEVEL)
+	l.SetExitFunc(func(int) {})
+	l.Debug("debug")
+	l.Info("info ", 1)
+	l.Warnf("warn %d", 2)
+	l.Errorw("error", alog.Int("n", 3))
+	l.Fatalj("fatal", nil)
+	l.LvEnable(alog.DEBUG)
+	l.Debugf("debug %t", true)
+
+	exp := "[INF] info 1\n[WRN] warn 2\n[ERR] error n=3\n[FTL] fatal{}\n[DBG] debug true\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+
+// closeRecorder is a bytes.Buffer that records Close() calls
+type closeRecorder struct {
+	bytes.Buffer
+	closed int
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed++
+	return nil
+}
+
+func Test_ALog_Fatal(t *testing.T) {
+	var out closeRecorder
+	var steps []string
+	l := alog.New(&out, "", alog.F_USE_BUF_1K)
+	l.AddExitHook(func() {
+		steps = append(steps, "hook:"+out.String())
+	})
+	l.SetExitFunc(func(code int) {
+		steps = append(steps, "exit:"+strconv.Itoa(code))
+	})
+	l.Info("buffered")
+	l.Fatalf("fatal: %s", "bye")
+
+	exp := "hook:buffered\nfatal: bye\n,exit:1"
+	if act := strings.Join(steps, ","); act != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, act)
+	}
+	if out.closed != 1 {
+		t.Fatalf("output is expected to be closed once; act=%d", out.closed)
+	}
+}
+func Test_ALog_Panic(t *testing.T) {
+	var out bytes.Buffer
+	l := alog.New(&out, "", alog.F_LEVEL|alog.F_USE_BUF_1K)
+	l.Info("buffered")
+	func() {
+		defer func() {
+			if r := recover(); r != "panic: 1" {
+				t.Fatalf("unexpected recover: %v", r)
+			}
+		}()
+		l.Panicf("panic: %d", 1)
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != `data: {"n":1}` {
+				t.Fatalf("unexpected recover: %v", r)
+			}
+		}()
+		l.Panicj("data: ", map[string]int{"n": 1})
+	}()
+
+	exp := "[INF] buffered\n[PNC] panic: 1\n[PNC] data: {\"n\":1}\n"
+	if out.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, out.String())
+	}
+}
+func Test_ALog_Level_Tag(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "app: ", alog.F_PREFIX|alog.F_LEVEL)
+	l.LvEnable(alog.ALL)
+	l.Printl(alog.DEBUG, "debug")
+	l.Printfl(alog.WARN, "%s", "warn")
+	l.Print("no level")
+	l.SetFlag(alog.F_PREFIX | alog.F_LEVEL_FULL)
+	l.Printwl(alog.FATAL, "fatal")
+	l.Printjl(alog.ERROR, "error", nil)
+
+	exp := "[DBG] app: debug\n[WRN] app: warn\napp: no level\n[FATAL] app: fatal\n[ERROR] app: error{}\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+func Test_ALog_Printw(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", 0)
+	l.Printw("done", alog.Str("user", "gon"), alog.Int("n", 3), alog.Float("ratio", 0.5),
+		alog.Bool("ok", true), alog.Uint("big", 1<<63), alog.Err(nil))
+	l.Printwl(alog.DEBUG, "hidden", alog.Int("n", 1))
+	l.Printwl(alog.ERROR, "failed", alog.Err(errors.New("disk full")))
+
+	exp := "done user=gon n=3 ratio=0.5 ok=true big=9223372036854775808 error=nil\nfailed error=disk full\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+
+type myInt int
+type myStr string
+
+// nilStringer panics with a nil receiver, panicStringer always panics,
+// and keptStringer keeps its receiver after String returns.
+type nilStringer struct{ s string }
+type panicStringer struct{}
+type keptStringer struct{ n int }
+type point struct{ X, Y int }
+
+// myFmt and fmtErr have their own Format, and goStr has GoString.
+type myFmt int
+type fmtErr struct{}
+type goStr int
+
+var kept []*keptStringer
+
+func (n *nilStringer) String() string       { return n.s }
+func (panicStringer) String() string        { panic("boom") }
+func (k *keptStringer) String() string      { kept = append(kept, k); return "kept" }
+func (myFmt) Format(f fmt.State, verb rune) { fmt.Fprintf(f, "FORMATTED(%c)", verb) }
+func (fmtErr) Error() string                { return "plain" }
+func (fmtErr) Format(f fmt.State, verb rune) {
+	if f.Flag('+') {
+		f.Write([]byte("detailed"))
+		return
+	}
+	f.Write([]byte("plain"))
+}
+func (goStr) GoString() string { return "goStr!" }
+
+func Test_ALog_Printf_Verbs(t *testing.T) {
+	ptr := 1
+	ptrs := []int{1}
+	var b bytes.Buffer
+	l := alog.New(&b, "", 0)
+	tests := []struct {
+		format string
+		a      []interface{}
+	}{
+		{"%d|%5d|%-5d|%05d|%+d|% d|%.3d|%08.3d|%.0d", []interface{}{-42, 42, 42, -42, 42, 42, 7, -42, 0}},
+		{"%d %d %d %d %d", []interface{}{int8(-8), int16(16), int32(-32), int64(1 << 62), uint64(1 << 63)}},
+		{"%x|%X|%#x|%o|%#o|%O|%b|%#b|%c|%q|%U", []interface{}{255, -255, 255, 8, 8, 8, 5, 5, 'é', 'x', 'x'}},
+		{"%s|%10s|%-10s|%.2s|%05s|%q|%#q|%+q|%x|% x|%X", []interface{}{"a", "b", "c", "héllo", "ab", "a\"b", "a", "é", "hi", "hi", []byte("az")}},
+		{"%v|%t|%6v|%s|%v", []interface{}{true, false, true, []byte("bytes"), nil}},
+		{"%v|%.3f|%8.3f|%-8.2f|%08.3f|%+.1f|%e|%.2E|%g|%.3g|%G|%v", []interface{}{1.5, 3.14159, -2.5, 2.5, -3.14159, 2.0, 12345.678, 0.000123, 1e21, 3.14159, 1e-7, float32(0.1)}},
+		{"%v|%s|%q|%10v|%d", []interface{}{errors.New("err"), errors.New("e2"), errors.New("q"), time.Second, time.Second}},
+		{"%p|%#p|%p|%p|%T|%T|%d|%05v|%x|%s", []interface{}{&ptr, &ptr, ptrs, nil, 1, time.Second, time.Second, myInt(-3), myStr("hi"), myStr("hi")}},
+		{"%U|%#U|%.6U|% x|% #X|%#x|%q|%.2s|%v", []interface{}{'x', 'é', 'x', "hi", "hi", []byte("hi"), []byte("q"), []byte("héllo"), []byte("ab")}},
+		{"%v|%.2g|%+.1e|%T", []interface{}{complex(1, -2), complex64(complex(1.5, 2)), complex(-1, 0.5), complex(1, 1)}},
+		{"%*d|%-*d|%.*f|%z|%!|%d %d", []interface{}{5, 1, 5, 2, 2, 3.14159, 1, 2, 3}},
+		{"%%|%5%|%s", []interface{}{}},
+		{"%v|%s|%5v|%v", []interface{}{(*nilStringer)(nil), panicStringer{}, (*nilStringer)(nil), &nilStringer{"ok"}}},
+		{"%v|%s|%v|%v|%+v|%d", []interface{}{[]string{"a", "b"}, []string{"c"}, struct{ X, Y int }{1, 2}, map[string]int{"a": 1}, &point{1, 2}, []int{1, 2}}},
+		{"%d|%s", []interface{}{1, "a", 2, nil, point{3, 4}}},
+		{"%v|%d|%v|%+v|%s|%#v|%v", []interface{}{myFmt(1), myFmt(2), fmtErr{}, fmtErr{}, myFmt(3), goStr(4), goStr(5)}},
+		{"%p|%p|%#p|%v", []interface{}{(func())(nil), map[string]int(nil), (func())(nil), map[string]int(nil)}},
+	}
+	for _, tc := range tests {
+		b.Reset()
+		l.Printf(tc.format, tc.a...)
+
+		exp := fmt.Sprintf(tc.format, tc.a...) + "\n"
+		if b.String() != exp {
+			t.Errorf("%s: exp=<%s>; act=<%s>", tc.format, exp, b.String())
+		}
+	}
+
+	// unlike fmt, %f uses 2 decimal places by default.
+	b.Reset()
+	l.Printf("%f|%6f|%d", 3.14159, 2.5, 1)
+	exp := "3.14|  2.50|1\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+
+	// a receiver kept by String must stay valid after Printf returns.
+	kept = nil
+	func() {
+		k := keptStringer{n: 7}
+		l.Printf("%v", &k)
+	}()
+	runtime.GC()
+	if len(kept) != 1 || kept[0].n != 7 {
+		t.Fatalf("unexpected: exp=<7>; act=<%v>", kept)
+	}
+}
+func Test_ALog_Printf_Float(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", 0)
+	nan, inf := math.NaN(), math.Inf(1)
+	tests := []struct {
+		format string
+		a      []interface{}
+	}{
+		{"%.2f|%.2f|%.2f|%.0f|%.3f", []interface{}{1.999, -1.999, 1.005, 2.5, 0.0005}},
+		{"%.2f|%.1f|%.3f|%.2f", []interface{}{1e20, -1e300, float64(math.MaxInt64) * 4, float32(1.5)}},
+		{"%.2f|%.2f|%.2f|%+.2f|% .2f|%8.2f|%08.2f|%-6.1f|", []interface{}{nan, inf, -inf, inf, inf, nan, -inf, nan}},
+		{"%+.2f|% .2f|%+e|%g|%.2f|%.2f", []interface{}{nan, nan, inf, -inf, math.Copysign(0, -1), -0.001}},
+	}
+	for _, tc := range tests {
+		b.Reset()
+		l.Printf(tc.format, tc.a...)
+		exp := fmt.Sprintf(tc.format, tc.a...) + "\n"
+		if b.String() != exp {
+			t.Errorf("%s: exp=<%s>; act=<%s>", tc.format, exp, b.String())
+		}
+	}
+
+	// default precision for %f
+	b.Reset()
+	l.Printf("%f", 1.999)
+	l.SetFloatPrecision(4)
+	l.Printf("%f|%.1f", 3.14159, 3.14159)
+	l.SetFloatPrecision(-1)
+	l.Printf("%f|%f", 0.1, float32(0.1))
+	exp := "2.00\n3.1416|3.1\n0.1|0.1\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+func Test_ALog_JSON(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "app", alog.F_PREFIX|alog.F_JSON)
+	l.Print("quote\"", 1, "\n")
+	l.Printf("%s=%d", "tab\t", 2)
+	l.Printl(alog.WARN, "warn")
+	l.Printfl(alog.ERROR, "%t", true)
+	l.Printj("data", struct {
+		Name string `json:"name"`
+	}{Name: "gon"})
+	l.Printjl(alog.INFO, "", nil)
+	l.Printwl(alog.INFO, "done", alog.Str("user", "gon"), alog.Float("f", 0.5), alog.Err(nil))
+
+	exp := `{"prefix":"app","msg":"quote\"1\n"}
+{"prefix":"app","msg":"tab\t=2"}
+{"level":"warn","prefix":"app","msg":"warn"}
+{"level":"error","prefix":"app","msg":"true"}
+{"prefix":"app","msg":"data","data":{"name":"gon"}}
+{"level":"info","prefix":"app","msg":"","data":{}}
+{"level":"info","prefix":"app","msg":"done","user":"gon","f":0.5,"error":null}
+`
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+
+	b.Reset()
+	l.SetFlag(alog.F_JSON | alog.F_DATE | alog.F_UTC)
+	l.Print("time")
+	var m map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Fatalf("invalid json: %s: %s", err, b.String())
+	}
+	if _, err := time.Parse(time.RFC3339, m["time"].(string)); err != nil {
+		t.Fatalf("invalid time: %s", err)
+	}
+
+	// nil first; encoder must still be created for the next one
+	b.Reset()
+	l = alog.New(&b, "", 0)
+	l.Printj("a|", nil)
+	l.Printj("b|", map[string]int{"n": 1})
+	exp = "a|{}\nb|{\"n\":1}\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+func Test_ALog_Logfmt(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "my app", alog.F_PREFIX|alog.F_LOGFMT)
+	l.Print("hello")
+	l.Printfl(alog.WARN, "disk %s", "full")
+	l.Printwl(alog.INFO, "", alog.Str("user", "gon"), alog.Str("q", `say "hi"`), alog.Str("eq", "a=b"),
+		alog.Str("a b=\"c\"\n", "x y"), alog.Str("", "v"), alog.Int("n", 3), alog.Err(errors.New("bad\nline")))
+	l.Printj("data", struct {
+		Name string `json:"name"`
+	}{Name: "gon"})
+
+	exp := `prefix="my app" msg=hello
+level=warn prefix="my app" msg="disk full"
+level=info prefix="my app" msg="" user=gon q="say \"hi\"" eq="a=b" a_b__c__="x y" _=v n=3 error="bad\nline"
+prefix="my app" msg=data data="{\"name\":\"gon\"}"
+`
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+
+// csvEncoder is a custom encoder for Test_ALog_Encoder: `level,prefix,msg,key=value`
+type csvEncoder struct{}
+
+func (csvEncoder) Begin(dst *[]byte) {}
+func (csvEncoder) Header(dst *[]byte, h *alog.Header) {
+	if h.Level == alog.WARN {
+		*dst = append(*dst, "warn"...)
+	}
+	*dst = append(*dst, ',')
+	*dst = append(*dst, h.Prefix...)
+	*dst = append(*dst, ',')
+}
+func (csvEncoder) Message(dst *[]byte, msg []byte) { *dst = append(*dst, msg...) }
+func (csvEncoder) Field(dst *[]byte, f alog.Field) {
+	*dst = append(*dst, ',')
+	*dst = append(*dst, f.Key...)
+	*dst = append(*dst, '=')
+	*dst = append(*dst, f.Str...)
+}
+func (csvEncoder) End(dst *[]byte) { *dst = append(*dst, '\n') }
+
+func Test_ALog_Encoder(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "app", alog.F_JSON)
+	l.SetEncoder(csvEncoder{})
+	l.Printwl(alog.WARN, "hello", alog.Str("user", "gon"))
+	l.SetEncoder(nil) // back to the flag
+	l.Print("hello")
+
+	exp := "warn,app,hello,user=gon\n{\"msg\":\"hello\"}\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+func Test_ALog_Printj(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", 0)
+	l.Printj("log|", struct {
+		Name string `json:"name"`
+	}{Name: "gon"})
+	l.Printj("nil|", nil)
+
+	exp := "log|{\"name\":\"gon\"}\nnil|{}\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
 func Test_ALog_Close(t *testing.T) {
 	tmpFile := "./tmp/alog.close.txt"
 	// Create file
@@ -161,13 +523,915 @@ func Test_ALog_Close(t *testing.T) {
 		}
 	}
 }
+func Test_ALog_RotatingFile(t *testing.T) {
+	dir := "./tmp/rotate"
+	os.RemoveAll(dir)
+	path := dir + "/alog.txt"
+
+	out, err := alog.NewRotatingFile(path, 30, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	l := alog.New(out, "", 0)
+	for i := 0; i < 10; i++ {
+		l.Print("entry-", i) // 8 bytes each; 3 entries per file
+	}
+	l.Close()
+
+	for _, c := range []struct{ file, exp string }{
+		{path, "entry-9\n"},
+		{path + ".1", "entry-6\nentry-7\nentry-8\n"},
+		{path + ".2", "entry-3\nentry-4\nentry-5\n"},
+	} {
+		b, err := ioutil.ReadFile(c.file)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(b) != c.exp {
+			t.Fatalf("unexpected: exp=<%s>; act=<%s>", c.exp, string(b))
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("unexpected: exp=<pruned>; act=<%v>", err)
+	}
+	if _, err := out.Write([]byte("closed\n")); err == nil {
+		t.Fatalf("unexpected: exp=<error>; act=<nil>")
+	}
+}
+func Test_ALog_RotatingFile_Buf(t *testing.T) {
+	dir := "./tmp/rotate_buf"
+	os.RemoveAll(dir)
+	path := dir + "/alog.txt"
+
+	out, err := alog.NewRotatingFile(path, 100, 5)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	l := alog.New(out, "", alog.F_USE_BUF_1K)
+	for i := 0; i < 1000; i++ {
+		l.Print("entry-", i)
+	}
+	l.Close()
+
+	// each file must only have whole entries
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) < 2 || len(files) > 6 {
+		t.Fatalf("unexpected: exp=<2..6 files>; act=<%d>", len(files))
+	}
+	for _, f := range files {
+		b, _ := ioutil.ReadFile(dir + "/" + f.Name())
+		for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			if !strings.HasPrefix(line, "entry-") {
+				t.Fatalf("unexpected: exp=<entry-N>; act=<%s>", line)
+			}
+		}
+	}
+}
+func Test_ALog_RotatingFile_TimeSuffix(t *testing.T) {
+	dir := "./tmp/rotate_time"
+	os.RemoveAll(dir)
+	path := dir + "/alog.txt"
+
+	out, err := alog.NewRotatingFile(path, 0, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	out.SetTimeSuffix("20060102-150405")
+	l := alog.New(out, "", 0)
+	for i := 0; i < 5; i++ {
+		l.Print("entry-", i)
+		if err := out.Rotate(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	l.Print("last")
+	l.Close()
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 3 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 3, len(files))
+	}
+	b, _ := ioutil.ReadFile(dir + "/" + files[2].Name()) // newest backup; alog.txt comes first
+	if string(b) != "entry-4\n" {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", "entry-4\n", string(b))
+	}
+}
+func Test_ALog_RotatingFile_Daily(t *testing.T) {
+	dir := "./tmp/rotate_daily"
+	os.RemoveAll(dir)
+
+	out, err := alog.NewTimedRotatingFile(dir+"/app-%Y-%m-%d.log", alog.ROTATE_DAILY, 0, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now := time.Date(2026, 10, 18, 23, 0, 0, 0, time.FixedZone("EST", -5*3600)) // 10/19 in UTC
+	out.SetClock(func() time.Time { return now })
+	l := alog.New(out, "", alog.F_UTC) // F_UTC is passed to the output
+
+	for i := 0; i < 4; i++ {
+		l.Print("day-", i)
+		now = now.Add(24 * time.Hour)
+	}
+	l.Close()
+
+	var act []string
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		act = append(act, f.Name())
+	}
+	exp := "app-2026-10-20.log,app-2026-10-21.log,app-2026-10-22.log"
+	if strings.Join(act, ",") != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, strings.Join(act, ","))
+	}
+	b, _ := ioutil.ReadFile(dir + "/app-2026-10-21.log")
+	if string(b) != "day-2\n" {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", "day-2\n", string(b))
+	}
+}
+func Test_ALog_RotatingFile_Hourly_Gzip(t *testing.T) {
+	dir := "./tmp/rotate_hourly"
+	os.RemoveAll(dir)
+
+	out, err := alog.NewTimedRotatingFile(dir+"/app-%Y%m%d-%H.log", alog.ROTATE_HOURLY, 0, 5)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now := time.Date(2026, 10, 18, 10, 59, 59, 0, time.UTC)
+	out.SetClock(func() time.Time { return now })
+	out.SetCompress(true)
+	l := alog.New(out, "", 0)
+
+	l.Print("hour-10")
+	now = now.Add(time.Second)
+	l.Print("hour-11")
+	l.Close()
+
+	b, err := ioutil.ReadFile(dir + "/app-20261018-11.log")
+	if err != nil || string(b) != "hour-11\n" {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s> (%v)", "hour-11\n", string(b), err)
+	}
+	f, err := os.Open(dir + "/app-20261018-10.log.gz")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	b, _ = ioutil.ReadAll(zr)
+	if string(b) != "hour-10\n" {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", "hour-10\n", string(b))
+	}
+	if _, err := os.Stat(dir + "/app-20261018-10.log"); !os.IsNotExist(err) {
+		t.Fatalf("unexpected: exp=<removed>; act=<%v>", err)
+	}
+}
+
+// gateWriter blocks each Write until gate is closed, and reports a started write to `started`
+type gateWriter struct {
+	bytes.Buffer
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	w.started <- struct{}{}
+	<-w.gate
+	return w.Buffer.Write(p)
+}
+func Test_ALog_Async(t *testing.T) {
+	for _, c := range []struct {
+		policy  alog.Overflow
+		exp     string
+		dropped uint64
+	}{
+		{alog.OVERFLOW_DROP_NEWEST, "e0\ne1\ne2\n", 1},
+		{alog.OVERFLOW_DROP_OLDEST, "e0\ne2\ne3\n", 1},
+	} {
+		w := &gateWriter{started: make(chan struct{}, 10), gate: make(chan struct{})}
+		l := alog.New(w, "", 0)
+		l.SetAsync(2, c.policy)
+
+		l.Print("e0")
+		<-w.started // e0 is being written; the queue is empty
+		l.Print("e1")
+		l.Print("e2")
+		l.Print("e3") // queue is full
+		close(w.gate)
+		l.Flush()
+
+		if w.String() != c.exp {
+			t.Fatalf("unexpected: exp=<%s>; act=<%s>", c.exp, w.String())
+		}
+		if l.Dropped() != c.dropped {
+			t.Fatalf("unexpected: exp=<%d>; act=<%d>", c.dropped, l.Dropped())
+		}
+		l.Close()
+	}
+}
+func Test_ALog_Async_Block(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", alog.F_USE_BUF_1K)
+	l.SetAsync(4, alog.OVERFLOW_BLOCK)
+
+	var exp bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		l.Print("entry-", i)
+		exp.WriteString("entry-" + strconv.Itoa(i) + "\n")
+	}
+	l.Close() // flushes the buffer and drains the queue
+
+	if b.String() != exp.String() {
+		t.Fatalf("unexpected: exp=<%d bytes>; act=<%d bytes>", exp.Len(), b.Len())
+	}
+	if l.Dropped() != 0 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 0, l.Dropped())
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe to read while a logger writes from another goroutine
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (w *lockedBuffer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.b.Write(p)
+}
+func (w *lockedBuffer) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.b.String()
+}
+func Test_ALog_FlushInterval(t *testing.T) {
+	var b lockedBuffer
+	l := alog.New(&b, "", alog.F_USE_BUF_1K)
+	l.SetFlushInterval(10 * time.Millisecond)
+	l.Print("test")
+
+	exp := "test\n"
+	for i := 0; i < 100 && b.String() != exp; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+	l.Close()
+}
+func Test_ALog_FlushLevel(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", alog.F_USE_BUF_1K)
+	l.SetFlushLevel(alog.ERROR)
+
+	l.Info("info")
+	if b.Len() != 0 {
+		t.Fatalf("unexpected: exp=<>; act=<%s>", b.String())
+	}
+	l.Error("error")
+	exp := "info\nerror\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+
+// countWriter counts Write calls
+type countWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n++
+	return w.Buffer.Write(p)
+}
+func Test_ALog_BufferSize(t *testing.T) {
+	w := &countWriter{}
+	l := alog.NewWithOptions(w, alog.WithPrefix("test "), alog.WithFlag(alog.F_PREFIX), alog.WithBufferSize(64))
+	for i := 0; i < 10; i++ {
+		l.Print(i) // 7 bytes each; written out when over 64 bytes
+	}
+	if w.n != 1 || w.Len() != 70 {
+		t.Fatalf("unexpected: exp=<1 write, 70 bytes>; act=<%d write, %d bytes>", w.n, w.Len())
+	}
+
+	l.Print("x")
+	l.SetBufferSize(0) // flushes, then writes each entry
+	if w.n != 2 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 2, w.n)
+	}
+	l.Print("y")
+	if w.n != 3 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 3, w.n)
+	}
+
+	l.SetBufferSize(1 << 20)
+	for i := 0; i < 1000; i++ {
+		l.Print(i)
+	}
+	if w.n != 3 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 3, w.n)
+	}
+	l.Close()
+	if w.n != 4 || !strings.Contains(w.String(), "test 9\ntest x\ntest y\ntest 0\n") || !strings.HasSuffix(w.String(), "test 999\n") {
+		t.Fatalf("unexpected: exp=<4 writes>; act=<%d writes>", w.n)
+	}
+}
+
+// errWriter always fails
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func Test_ALog_AddOutput(t *testing.T) {
+	var main, warn, all bytes.Buffer
+	l := alog.New(&main, "", alog.F_LEVEL)
+	l.AddOutput(errWriter{}, alog.INFO|alog.ERROR, 0) // a failing output shouldn't affect others
+	l.AddOutput(&warn, alog.WARN|alog.ERROR, alog.F_LEVEL_FULL)
+	o := l.AddOutput(&all, alog.ALL, alog.F_JSON|alog.F_USE_BUF_1K)
+
+	if !l.LvIsEnabled(alog.DEBUG) {
+		t.Fatalf("unexpected: exp=<%t>; act=<%t>", true, false)
+	}
+	l.Debug("debug")
+	l.Infow("info", alog.Int("n", 1))
+	l.Warn("warn")
+	l.Print("print")
+	if all.Len() != 0 { // buffered
+		t.Fatalf("unexpected: exp=<>; act=<%s>", all.String())
+	}
+	l.Flush()
+
+	for _, c := range []struct{ exp, act string }{
+		{"[INF] info n=1\n[WRN] warn\nprint\n", main.String()},
+		{"[WARN] warn\nprint\n", warn.String()},
+		{`{"level":"debug","msg":"debug"}` + "\n" +
+			`{"level":"info","msg":"info","n":1}` + "\n" +
+			`{"level":"warn","msg":"warn"}` + "\n" +
+			`{"msg":"print"}` + "\n", all.String()},
+	} {
+		if c.act != c.exp {
+			t.Fatalf("unexpected: exp=<%s>; act=<%s>", c.exp, c.act)
+		}
+	}
+
+	l.RemoveOutput(o)
+	l.Error("error")
+	if !strings.HasSuffix(warn.String(), "[ERROR] error\n") || strings.Contains(all.String(), "error") {
+		t.Fatalf("unexpected: warn=<%s>; all=<%s>", warn.String(), all.String())
+	}
+	if l.LvIsEnabled(alog.DEBUG) {
+		t.Fatalf("unexpected: exp=<%t>; act=<%t>", false, true)
+	}
+}
+
+func Test_ALog_AddOutput_Concurrent(t *testing.T) {
+	l := alog.New(alog.Discard, "", 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.RemoveOutput(l.AddOutput(alog.Discard, alog.DEBUG, 0))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		l.Debug("debug") // checks levels of outputs without the lock
+	}
+	<-done
+}
+
+func Test_ALog_WriteError(t *testing.T) {
+	var fallback, handled bytes.Buffer
+	l := alog.New(errWriter{}, "", 0)
+	l.SetFallback(&fallback)
+	l.SetErrorHandler(func(err error) {
+		handled.WriteString(err.Error() + "\n")
+	})
+
+	l.Print("a")
+	l.SetAsync(2, alog.OVERFLOW_BLOCK) // errors from the async goroutine are reported too
+	l.Print("b")
+	l.Flush()
+
+	n, err := l.WriteErrors()
+	if n != 2 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 2, n)
+	}
+	var werr *alog.WriteError
+	if !errors.As(err, &werr) || werr.Err.Error() != "write failed" {
+		t.Fatalf("unexpected: exp=<*alog.WriteError>; act=<%v>", err)
+	}
+	if exp := "a\nb\n"; fallback.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, fallback.String())
+	}
+	if exp := "alog: write failed: write failed\n"; handled.String() != exp+exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp+exp, handled.String())
+	}
+	l.Close()
+}
+
+func Test_ALog_WriteError_Discard(t *testing.T) {
+	var fallback bytes.Buffer
+	l := alog.New(nil, "", 0) // Discard
+	l.AddOutput(nil, alog.ALL, 0)
+	l.SetFallback(&fallback)
+	l.Print("x")
+
+	if n, err := l.WriteErrors(); n != 0 || err != nil {
+		t.Fatalf("unexpected: exp=<0 <nil>>; act=<%d %v>", n, err)
+	}
+	if fallback.Len() != 0 {
+		t.Fatalf("unexpected: exp=<>; act=<%s>", fallback.String())
+	}
+}
+
+func Test_ALog_With(t *testing.T) {
+	var b, j bytes.Buffer
+	l := alog.New(&b, "main ", alog.F_PREFIX)
+	l.AddOutput(&j, alog.ALL, alog.F_JSON|alog.F_PREFIX)
+
+	rl := l.With(alog.Str("request_id", "r1"), alog.Int("user_id", 7))
+	rl.Print("start")
+	rl.WithPrefix("sub ").With(alog.Bool("sub", true)).Printw("done", alog.Int("n", 3))
+	l.Print("parent") // parent isn't affected
+
+	exp := "main start request_id=r1 user_id=7\n" +
+		"sub done request_id=r1 user_id=7 sub=true n=3\n" +
+		"main parent\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+	expJ := `{"prefix":"main ","msg":"start","request_id":"r1","user_id":7}` + "\n" +
+		`{"prefix":"sub ","msg":"done","request_id":"r1","user_id":7,"sub":true,"n":3}` + "\n" +
+		`{"prefix":"main ","msg":"parent"}` + "\n"
+	if j.String() != expJ {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", expJ, j.String())
+	}
+
+	// rendered fields follow a change of the encoder
+	b.Reset()
+	l.SetFlag(alog.F_LOGFMT)
+	rl.Print("again")
+	exp = "msg=again request_id=r1 user_id=7\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+
+type traceKey struct{}
+
+func Test_ALog_Context(t *testing.T) {
+	var b bytes.Buffer
+	l := alog.New(&b, "", alog.F_LEVEL)
+	l.AddContextHook(func(ctx context.Context, dst []alog.Field) []alog.Field {
+		if id, ok := ctx.Value(traceKey{}).(string); ok {
+			dst = append(dst, alog.Str("trace_id", id))
+		}
+		return dst
+	})
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "t1")
+	ctx = alog.ContextWith(ctx, alog.Str("request_id", "r1"))
+	ctx = alog.NewContext(ctx, l)
+
+	l.InfoCtx(ctx, "info", alog.Int("n", 1))
+	alog.WarnCtx(ctx, "warn") // uses the logger in ctx
+	alog.FromContext(ctx).Error("error")
+	l.DebugCtx(ctx, "debug") // disabled
+
+	exp := "[INF] info request_id=r1 trace_id=t1 n=1\n" +
+		"[WRN] warn request_id=r1 trace_id=t1\n" +
+		"[ERR] error request_id=r1 trace_id=t1\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+	if alog.FromContext(context.Background()) == nil {
+		t.Fatalf("unexpected: exp=<std>; act=<nil>")
+	}
+
+	// fields of ctx are added once for a logger from FromContext
+	b.Reset()
+	alog.FromContext(ctx).InfoCtx(ctx, "m", alog.Int("n", 2))
+	alog.FromContext(ctx).With(alog.Int("c", 3)).WarnCtx(ctx, "w")
+	exp = "[INF] m request_id=r1 trace_id=t1 n=2\n" +
+		"[WRN] w request_id=r1 trace_id=t1 c=3\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+
+	// a derived context adds its own fields and hook fields only
+	b.Reset()
+	rl := alog.FromContext(ctx)
+	rl.InfoCtx(alog.ContextWith(ctx, alog.Str("step", "db")), "q")
+	rl.InfoCtx(context.WithValue(ctx, traceKey{}, "t2"), "t")
+	rl.InfoCtx(alog.ContextWith(context.Background(), alog.Str("other", "o")), "o")
+	alog.InfoCtx(alog.ContextWith(alog.NewContext(ctx, rl), alog.Str("step", "db")), "std")
+	exp = "[INF] q request_id=r1 trace_id=t1 step=db\n" +
+		"[INF] t request_id=r1 trace_id=t1 trace_id=t2\n" +
+		"[INF] o request_id=r1 trace_id=t1 other=o\n" +
+		"[INF] std request_id=r1 trace_id=t1 step=db\n"
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+}
+
+func Test_ALog_SyslogWriter(t *testing.T) {
+	clock := func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC) }
+
+	// UDP, RFC 5424
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w, err := alog.NewSyslogWriter("udp", pc.LocalAddr().String(), alog.FACILITY_LOCAL0, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetHostname("host")
+	w.SetClock(clock)
+	l := alog.New(w, "", alog.F_LEVEL)
+	l.SetFlag(alog.F_LEVEL | alog.F_USE_BUF_1K) // written immediately regardless of the buffer
+	l.Warn("disk low")
+	l.Print("hello")
+
+	pid := strconv.Itoa(os.Getpid())
+	buf := make([]byte, 1024)
+	for _, exp := range []string{
+		"<132>1 2020-01-02T03:04:05.000006Z host app " + pid + " - - [WRN] disk low",
+		"<133>1 2020-01-02T03:04:05.000006Z host app " + pid + " - - hello",
+	} {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := string(buf[:n]); act != exp {
+			t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, act)
+		}
+	}
+	l.Close()
+
+	// TCP, RFC 3164, octet-counted
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		defer c.Close()
+		b, _ := ioutil.ReadAll(c)
+		got <- string(b)
+	}()
+	w, err = alog.NewSyslogWriter("tcp", ln.Addr().String(), alog.FACILITY_DAEMON, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFormat(alog.SYSLOG_RFC3164)
+	w.SetHostname("host")
+	w.SetClock(clock)
+	l = alog.New(w, "", 0)
+	l.Debug("not written")
+	l.Error("failed")
+	l.Info("line1\nline2")
+	l.Close()
+
+	m1 := "<27>Jan  2 03:04:05 host app[" + pid + "]: failed"
+	m2 := "<30>Jan  2 03:04:05 host app[" + pid + "]: line1\nline2"
+	exp := strconv.Itoa(len(m1)) + " " + m1 + strconv.Itoa(len(m2)) + " " + m2
+	if act := <-got; act != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, act)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Fatalf("unexpected: exp=<error>; act=<nil>")
+	}
+
+	// unix stream socket, newline-terminated
+	dir, err := ioutil.TempDir("", "alog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ln, err = net.Listen("unix", filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		defer c.Close()
+		b, _ := ioutil.ReadAll(c)
+		got <- string(b)
+	}()
+	w, err = alog.NewSyslogWriter("unix", filepath.Join(dir, "log"), alog.FACILITY_USER, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFormat(alog.SYSLOG_RFC3164)
+	w.SetHostname("host")
+	w.SetClock(clock)
+	l = alog.New(w, "", 0)
+	l.Info("a")
+	l.Warn("b")
+	l.Close()
+
+	exp = "<14>Jan  2 03:04:05 host app[" + pid + "]: a\n<12>Jan  2 03:04:05 host app[" + pid + "]: b\n"
+	if act := <-got; act != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, act)
+	}
+}
+
+func Test_ALog_NetWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // not listening yet; writes are spooled
+
+	w := alog.NewNetWriter("tcp", addr, nil)
+	w.SetBackoff(10*time.Millisecond, 20*time.Millisecond)
+	w.SetSpoolSize(4)
+	l := alog.New(w, "", 0)
+	l.Print("a")
+	l.Print("b")
+	l.Print("c") // "a\n" is dropped
+	if n := w.Dropped(); n != 2 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 2, n)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "b\nc\n" {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s> (%v)", "b\nc\n", buf, err)
+	}
+	// spool is sent; written directly
+	l.Print("d")
+	buf = buf[:2]
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "d\n" {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s> (%v)", "d\n", buf, err)
+	}
+
+	// lost connection
+	c.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+	for deadline := time.Now().Add(5 * time.Second); w.Reconnects() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected: exp=<reconnected>; act=<not reconnected>")
+		}
+		l.Print("e")
+	}
+	c = <-accepted
+	l.Print("f")
+	l.Close()
+	b, _ := ioutil.ReadAll(c)
+	c.Close()
+	if s := string(b); !strings.HasPrefix(s, "e\n") || !strings.HasSuffix(s, "e\nf\n") {
+		t.Fatalf("unexpected: exp=<e\\n...f\\n>; act=<%s>", s)
+	}
+	if _, err := w.Write([]byte("g\n")); err == nil {
+		t.Fatalf("unexpected: exp=<error>; act=<nil>")
+	}
+}
+
+func Test_ALog_NetWriter_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srv.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		b, _ := ioutil.ReadAll(c)
+		c.Close()
+		got <- string(b)
+	}()
+
+	w := alog.NewNetWriter("tcp", ln.Addr().String(), srv.Client().Transport.(*http.Transport).TLSClientConfig)
+	l := alog.New(w, "", alog.F_JSON)
+	l.Infow("hello", alog.Int("n", 1))
+	l.Close()
+	if exp, act := `{"level":"info","msg":"hello","n":1}`+"\n", <-got; act != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, act)
+	}
+}
+
+func Test_ALog_HTTPWriter(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls++; calls == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable) // retried
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			body = zr
+		}
+		b, _ := ioutil.ReadAll(body)
+		bodies = append(bodies, r.Header.Get("Content-Type")+" "+string(b))
+	}))
+	defer srv.Close()
+
+	w := alog.NewHTTPWriter(srv.URL)
+	w.SetBatch(2, 1<<20, time.Hour)
+	w.SetGzip(true)
+	w.SetRetry(3, time.Millisecond, time.Millisecond)
+	w.SetMaxInFlight(1)
+	l := alog.New(w, "", alog.F_JSON)
+	l.Print("a")
+	l.Print("b") // sent as a batch of 2
+	l.Print("c") // sent by Close
+	l.Close()
+
+	exp := []string{
+		"application/x-ndjson " + `{"msg":"a"}` + "\n" + `{"msg":"b"}` + "\n",
+		"application/x-ndjson " + `{"msg":"c"}` + "\n",
+	}
+	if fmt.Sprint(bodies) != fmt.Sprint(exp) {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, bodies)
+	}
+	if n := w.Dropped(); n != 0 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 0, n)
+	}
+
+	// sent by age
+	w = alog.NewHTTPWriter(srv.URL)
+	w.SetBatch(100, 1<<20, 10*time.Millisecond)
+	w.Write([]byte("d\n"))
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		mu.Lock()
+		n := len(bodies)
+		mu.Unlock()
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected: exp=<%d>; act=<%d>", 3, n)
+		}
+	}
+	w.Close()
+	if _, err := w.Write([]byte("e\n")); err == nil {
+		t.Fatalf("unexpected: exp=<error>; act=<nil>")
+	}
+}
+
+func Test_ALog_HTTPWriter_Fail(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest) // not retried
+	}))
+	defer srv.Close()
+
+	var handled []string
+	w := alog.NewHTTPWriter(srv.URL)
+	w.SetErrorHandler(func(err error) { handled = append(handled, err.Error()) })
+	l := alog.New(w, "", alog.F_USE_BUF_1K)
+	l.Print("a")
+	l.Print("b")
+	l.Close()
+
+	if n := w.Dropped(); n != 2 {
+		t.Fatalf("unexpected: exp=<%d>; act=<%d>", 2, n)
+	}
+	if exp := "[alog: write failed: alog: http status 400 Bad Request]"; fmt.Sprint(handled) != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, handled)
+	}
+}
+
+func Test_ALog_Caller(t *testing.T) {
+	var b, j bytes.Buffer
+	l := alog.New(&b, "", alog.F_CALLER)
+	l.AddOutput(&j, alog.INFO, alog.F_JSON|alog.F_LONGFILE|alog.F_FUNC)
+	wrap := func(msg string) { l.Info(msg) }
+
+	_, file, line, _ := runtime.Caller(0)
+	l.Info("a")
+	l.Printw("b", alog.Int("n", 1))
+	l.InfoCtx(context.Background(), "c")
+	l.SetCallerSkip(1)
+	wrap("d")
+	l.SetCallerSkip(0)
+	func() {
+		defer func() { recover() }()
+		l.Panic("e")
+	}()
+	alog.SetOutput(&b)
+	alog.SetFlag(alog.F_CALLER | alog.F_FUNC)
+	alog.Info("f")
+	alog.SetFlag(0)
+
+	exp := fmt.Sprintf("alog_test.go:%d: a\nalog_test.go:%d: b n=1\nalog_test.go:%d: c\nalog_test.go:%d: d\n"+
+		"alog_test.go:%d: e\nalog_test.go:%d alog_test.Test_ALog_Caller: f\n",
+		line+1, line+2, line+3, line+5, line+9, line+13)
+	if b.String() != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
+	}
+	exp = fmt.Sprintf(`{"level":"info","caller":"%s:%d","func":"alog_test.Test_ALog_Caller","msg":"a"}`+"\n", file, line+1)
+	if act := strings.SplitAfter(j.String(), "\n")[0]; act != exp {
+		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, act)
+	}
+}
+
+func Test_ALog_Stack(t *testing.T) {
+	var b, j bytes.Buffer
+	l := alog.New(&b, "", alog.F_LEVEL)
+	l.AddOutput(&j, alog.ERROR, alog.F_JSON)
+	l.SetStackLevel(alog.ERROR)
+
+	_, file, line, _ := runtime.Caller(0)
+	l.Warn("no stack")
+	l.Errorw("failed", alog.Int("n", 1))
+
+	exp := fmt.Sprintf("[WRN] no stack\n[ERR] failed n=1\n\talog_test.Test_ALog_Stack\n\t\t%s:%d\n\ttesting.tRunner\n", file, line+2)
+	if act := b.String(); !strings.HasPrefix(act, exp) || !strings.HasSuffix(act, "\n") {
+		t.Fatalf("unexpected: exp=<%s...>; act=<%s>", exp, act)
+	}
+
+	var entry struct {
+		Msg   string
+		N     int
+		Stack []struct {
+			Func string
+			File string
+			Line int
+		}
+	}
+	if err := json.Unmarshal(j.Bytes(), &entry); err != nil {
+		t.Fatalf("unexpected: %v; act=<%s>", err, j.String())
+	}
+	if entry.Msg != "failed" || entry.N != 1 || len(entry.Stack) < 2 {
+		t.Fatalf("unexpected: act=<%s>", j.String())
+	}
+	if s := entry.Stack[0]; s.Func != "alog_test.Test_ALog_Stack" || s.File != file || s.Line != line+2 {
+		t.Fatalf("unexpected: exp=<alog_test.Test_ALog_Stack %s:%d>; act=<%s %s:%d>", file, line+2, s.Func, s.File, s.Line)
+	}
+
+	// a mask of levels uses the lowest one
+	b.Reset()
+	l.SetStackLevel(alog.ERROR | alog.FATAL)
+	l.Warn("no stack")
+	l.Error("stack")
+	if exp := "[WRN] no stack\n[ERR] stack\n\talog_test.Test_ALog_Stack\n"; !strings.HasPrefix(b.String(), exp) {
+		t.Fatalf("unexpected: exp=<%s...>; act=<%s>", exp, b.String())
+	}
+}
+
 func Test_ALog_Std(t *testing.T) {
 	var b bytes.Buffer
 	alog.SetOutput(&b)
 	alog.SetFlag(0)
 	alog.Printf("test: %s", "alog")
+	alog.Debug("debug")
+	alog.LvEnable(alog.DEBUG)
+	alog.Debugw("debug", alog.Int("n", 1))
+	alog.Infof("info: %d", 2)
+	alog.LvDisable(alog.DEBUG)
 
-	exp := "test: alog\n"
+	alog.Default().With(alog.Int("n", 3)).Print("child")
+
+	exp := "test: alog\ndebug n=1\ninfo: 2\nchild n=3\n"
 	if b.String() != exp {
 		t.Fatalf("unexpected: exp=<%s>; act=<%s>", exp, b.String())
 	}
@@ -198,6 +1462,51 @@ func Benchmark_ALog_Print_Buf(b *testing.B) {
 	b.StopTimer()
 	b.ReportAllocs()
 }
+func Benchmark_ALog_Print_Async(b *testing.B) {
+	b.StartTimer()
+	out, _ := os.Create("./tmp/alog_print_async.txt")
+	x := alog.New(out, "test ", alog.F_STD)
+	x.SetAsync(1024, alog.OVERFLOW_BLOCK)
+	for i := 0; i < b.N; i++ {
+		x.Print("Print(): ", i, ", an", " ", "a", "w", 3, "s", "o", "m", 3)
+	}
+	x.Close()
+	b.StopTimer()
+	b.ReportAllocs()
+}
+func Benchmark_ALog_Printw(b *testing.B) {
+	b.StartTimer()
+	out, _ := os.Create("./tmp/alog_printw.txt")
+	x := alog.New(out, "test ", alog.F_STD)
+	for i := 0; i < b.N; i++ {
+		x.Printw("Printw()", alog.Int("i", i), alog.Str("name", "gon"), alog.Bool("ok", true))
+	}
+	x.Close()
+	b.StopTimer()
+	b.ReportAllocs()
+}
+func Benchmark_ALog_Info(b *testing.B) {
+	b.StartTimer()
+	out, _ := os.Create("./tmp/alog_info.txt")
+	x := alog.New(out, "test ", alog.F_STD|alog.F_LEVEL)
+	for i := 0; i < b.N; i++ {
+		x.Info("Info(): ", i, ", an", " ", "a", "w", 3, "s", "o", "m", 3)
+	}
+	x.Close()
+	b.StopTimer()
+	b.ReportAllocs()
+}
+func Benchmark_ALog_Info_Caller(b *testing.B) {
+	b.StartTimer()
+	out, _ := os.Create("./tmp/alog_info_caller.txt")
+	x := alog.New(out, "test ", alog.F_STD|alog.F_LEVEL|alog.F_CALLER)
+	for i := 0; i < b.N; i++ {
+		x.Info("Info(): ", i, ", an", " ", "a", "w", 3, "s", "o", "m", 3)
+	}
+	x.Close()
+	b.StopTimer()
+	b.ReportAllocs()
+}
 func Benchmark_ALog_Printfl(b *testing.B) {
 	b.StartTimer()
 	out, _ := os.Create("./tmp/alog_printfl.txt")
@@ -221,6 +1530,18 @@ func Benchmark_ALog_Printf(b *testing.B) {
 	b.StopTimer()
 	b.ReportAllocs()
 }
+func Benchmark_ALog_Printf_Verbs(b *testing.B) {
+	b.StartTimer()
+	out, _ := os.Create("./tmp/alog_printf_verbs.txt")
+	x := alog.New(out, "test ", alog.F_STD)
+	err := errors.New("err")
+	for i := 0; i < b.N; i++ {
+		x.Printf("%-8s|%08.3f|%#x|%v|%d|%5t", "verbs", float64(i)/3, i, err, time.Duration(i), true)
+	}
+	x.Close()
+	b.StopTimer()
+	b.ReportAllocs()
+}
 func Benchmark_ALog_Printf_Buf(b *testing.B) {
 	b.StartTimer()
 	out, _ := os.Create("./tmp/alog_printf_buf.txt")
@@ -256,6 +1577,28 @@ func Benchmark_ALog_Printj(b *testing.B) {
 	b.StopTimer()
 	b.ReportAllocs()
 }
+func Benchmark_ALog_With(b *testing.B) {
+	b.StartTimer()
+	out, _ := os.Create("./tmp/alog_with.txt")
+	x := alog.New(out, "test ", alog.F_STD).With(alog.Str("request_id", "a1b2c3"), alog.Int("user_id", 123))
+	for i := 0; i < b.N; i++ {
+		x.Infow("Infow()", alog.Int("i", i))
+	}
+	x.Close()
+	b.StopTimer()
+	b.ReportAllocs()
+}
+func Benchmark_ALog_Printw_JSON(b *testing.B) {
+	b.StartTimer()
+	out, _ := os.Create("./tmp/alog_printw_json.txt")
+	x := alog.New(out, "test", alog.F_STD|alog.F_JSON)
+	for i := 0; i < b.N; i++ {
+		x.Printwl(alog.INFO, "Printw()", alog.Int("i", i), alog.Str("name", "gon"), alog.Bool("ok", true))
+	}
+	x.Close()
+	b.StopTimer()
+	b.ReportAllocs()
+}
 func Benchmark_ALog_Printj_Buf(b *testing.B) {
 	out, _ := os.Create("./tmp/alog_printj_buf.txt")
 	x := alog.New(out, "jsonTest", alog.F_STD|alog.F_USE_BUF_2K)
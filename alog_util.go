@@ -2,7 +2,56 @@
 
 package alog
 
-import "io"
+import (
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// =====================================================================================================================
+// MESSAGE FORMATTING
+// =====================================================================================================================
+
+// appendPrint appends values of `a` without any separator
+func appendPrint(dst *[]byte, a []interface{}) {
+	for _, v := range a {
+		switch v := v.(type) {
+		case string:
+			*dst = append(*dst, v...)
+		case int:
+			*dst = strconv.AppendInt(*dst, int64(v), 10)
+		case int8:
+			*dst = strconv.AppendInt(*dst, int64(v), 10)
+		case int16:
+			*dst = strconv.AppendInt(*dst, int64(v), 10)
+		case int32:
+			*dst = strconv.AppendInt(*dst, int64(v), 10)
+		case int64:
+			*dst = strconv.AppendInt(*dst, v, 10)
+		case bool:
+			*dst = strconv.AppendBool(*dst, v)
+		case uint:
+			*dst = strconv.AppendUint(*dst, uint64(v), 10)
+		case uint8:
+			*dst = strconv.AppendUint(*dst, uint64(v), 10)
+		case uint16:
+			*dst = strconv.AppendUint(*dst, uint64(v), 10)
+		case uint32:
+			*dst = strconv.AppendUint(*dst, uint64(v), 10)
+		case uint64:
+			*dst = strconv.AppendUint(*dst, v, 10)
+		case float32:
+			*dst = strconv.AppendFloat(*dst, float64(v), 'f', -1, 32)
+		case float64:
+			*dst = strconv.AppendFloat(*dst, v, 'f', -1, 64)
+		case []byte:
+			*dst = append(*dst, v...)
+		default:
+			*dst = append(*dst, unsuppType...)
+		}
+	}
+}
 
 // =====================================================================================================================
 // INT TO []BYTE
@@ -37,29 +86,160 @@ func itoa(dst *[]byte, i int, minLength int) {
 }
 
 // =====================================================================================================================
-// INT TO []BYTE
+// FLOAT TO []BYTE
+// =====================================================================================================================
+
+// ftoa converts float64 to []byte with decPlace digits after the decimal point.
+// It's rounded to the nearest the same as fmt's %f, and a negative decPlace uses
+// the shortest representation. NaN and infinities are written as `NaN`, `+Inf` and `-Inf`.
+// bitSize is 32 for float32 values, which only matters for the shortest representation.
+func ftoa(dst *[]byte, f float64, decPlace int, bitSize int) {
+	switch {
+	case f != f:
+		*dst = append(*dst, "NaN"...)
+	case math.IsInf(f, 1):
+		*dst = append(*dst, "+Inf"...)
+	case math.IsInf(f, -1):
+		*dst = append(*dst, "-Inf"...)
+	default:
+		if decPlace < 0 {
+			decPlace = -1
+		}
+		// strconv rounds with the exact decimal value of f, and handles any magnitude
+		// without going through int which overflows beyond 1<<63.
+		*dst = strconv.AppendFloat(*dst, f, 'f', decPlace, bitSize)
+	}
+}
+
 // =====================================================================================================================
+// JSON STRING
+// =====================================================================================================================
+const hexDigits = "0123456789abcdef"
 
-// ftoa converts float64 to []byte
-func ftoa(dst *[]byte, f float64, decPlace int) {
-	if int(f) == 0 && f < 0 {
-		*dst = append(*dst, '-')
+// appendJSONString appends a quoted and escaped JSON string
+func appendJSONString(dst *[]byte, s string) {
+	*dst = append(*dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				*dst = append(*dst, s[start:i]...)
+				*dst = append(*dst, `\ufffd`...)
+				i += size
+				start = i
+				continue
+			}
+			i += size
+			continue
+		}
+		if c >= 0x20 && c != '"' && c != '\\' {
+			i++
+			continue
+		}
+		*dst = append(*dst, s[start:i]...)
+		appendJSONEscape(dst, c)
+		i++
+		start = i
 	}
-	itoa(dst, int(f), 0) // add full number first
-
-	if decPlace > 0 {
-		// if decPlace == 3, multiplier will be 1000
-		// get nth power
-		var multiplier int = 1
-		for i := decPlace; i > 0; i-- {
-			multiplier = multiplier * 10
+	*dst = append(*dst, s[start:]...)
+	*dst = append(*dst, '"')
+}
+
+// appendJSONBytes is same as appendJSONString but for []byte
+func appendJSONBytes(dst *[]byte, s []byte) {
+	*dst = append(*dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRune(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				*dst = append(*dst, s[start:i]...)
+				*dst = append(*dst, `\ufffd`...)
+				i += size
+				start = i
+				continue
+			}
+			i += size
+			continue
+		}
+		if c >= 0x20 && c != '"' && c != '\\' {
+			i++
+			continue
 		}
-		*dst = append(*dst, '.')
-		tmp := int((f - float64(int(f))) * float64(multiplier))
-		if f > 0 { // 2nd num shouldn't include decimala
-			itoa(dst, tmp, decPlace)
+		*dst = append(*dst, s[start:i]...)
+		appendJSONEscape(dst, c)
+		i++
+		start = i
+	}
+	*dst = append(*dst, s[start:]...)
+	*dst = append(*dst, '"')
+}
+
+func appendJSONEscape(dst *[]byte, c byte) {
+	switch c {
+	case '"', '\\':
+		*dst = append(*dst, '\\', c)
+	case '\n':
+		*dst = append(*dst, '\\', 'n')
+	case '\r':
+		*dst = append(*dst, '\\', 'r')
+	case '\t':
+		*dst = append(*dst, '\\', 't')
+	default:
+		*dst = append(*dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+	}
+}
+
+// =====================================================================================================================
+// LOGFMT VALUE
+// =====================================================================================================================
+
+// appendLogfmtString appends a logfmt value; a value is quoted and escaped only when
+// it's empty or has a space, `=`, `"`, a control character, or an invalid UTF-8.
+func appendLogfmtString(dst *[]byte, s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '=' || c == '"' || c == 0x7f || (c >= utf8.RuneSelf && !utf8.ValidString(s)) {
+			appendJSONString(dst, s)
+			return
+		}
+	}
+	if len(s) == 0 {
+		*dst = append(*dst, '"', '"')
+		return
+	}
+	*dst = append(*dst, s...)
+}
+
+// appendLogfmtBytes is same as appendLogfmtString but for []byte
+func appendLogfmtBytes(dst *[]byte, s []byte) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '=' || c == '"' || c == 0x7f || (c >= utf8.RuneSelf && !utf8.Valid(s)) {
+			appendJSONBytes(dst, s)
+			return
+		}
+	}
+	if len(s) == 0 {
+		*dst = append(*dst, '"', '"')
+		return
+	}
+	*dst = append(*dst, s...)
+}
+
+// appendLogfmtKey appends a key with spaces, `=`, `"` and control characters replaced by `_`
+// as keys can't be quoted in logfmt. An empty key is written as `_`.
+func appendLogfmtKey(dst *[]byte, s string) {
+	if s == "" {
+		*dst = append(*dst, '_')
+		return
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			*dst = append(*dst, '_')
 		} else {
-			itoa(dst, -tmp, decPlace)
+			*dst = append(*dst, c)
 		}
 	}
 }
@@ -74,6 +254,11 @@ func (cb *tinyBuffer) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// MarshalJSON returns the buffer as is; it's used to pass an already encoded JSON as a Field.
+func (cb *tinyBuffer) MarshalJSON() ([]byte, error) {
+	return *cb, nil
+}
+
 // =====================================================================================================================
 // UTILS
 // =====================================================================================================================
@@ -82,5 +267,5 @@ type devNull int
 var Discard io.Writer = devNull(0)
 
 func (devNull) Write(p []byte) (int, error) {
-	return 0, nil
+	return len(p), nil
 }
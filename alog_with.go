@@ -0,0 +1,85 @@
+// (c) 2020 Gon Y Yi. <https://gonyyi.com/copyright.txt>
+
+package alog
+
+// =====================================================================================================================
+// A LOGGER / CHILD
+// =====================================================================================================================
+
+// With returns a child logger which adds fields to every entry, before fields given to each call:
+//
+//	rl := l.With(alog.Str("request_id", id), alog.Str("user_id", uid))
+//	rl.Infow("done", alog.Int("n", 3)) // done request_id=... user_id=... n=3
+//
+// A child shares the output, buffer, lock, and settings (flag, level, outputs, ...) with its parent;
+// only the prefix and fields are its own. Fields are rendered once per encoder, not for every entry.
+func (l *ALogger) With(fields ...Field) *ALogger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := &ALogger{core: l.core, prefix: l.prefix, ctxBase: l.ctxBase}
+	var parent []Field
+	if l.preset != nil {
+		parent = l.preset.fields
+	}
+	if n := len(parent) + len(fields); n > 0 {
+		c.preset = &preset{fields: make([]Field, 0, n)}
+		c.preset.fields = append(c.preset.fields, parent...)
+		c.preset.fields = append(c.preset.fields, fields...)
+	}
+	return c
+}
+
+// WithPrefix returns a child logger with its own prefix. See With.
+func (l *ALogger) WithPrefix(prefix string) *ALogger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &ALogger{core: l.core, prefix: []byte(prefix), preset: l.preset.clone(), ctxBase: l.ctxBase}
+}
+
+// preset is fields of a child logger. Rendered fields are cached for each output, and
+// used while core.encGen is unchanged. It's guarded by core.mu.
+type preset struct {
+	fields []Field
+	cache  []presetCache
+}
+
+type presetCache struct {
+	owner *sink
+	gen   uint64
+	b     []byte
+}
+
+// clone returns a preset with the same fields but without the cache
+func (p *preset) clone() *preset {
+	if p == nil {
+		return nil
+	}
+	return &preset{fields: p.fields}
+}
+
+// render returns fields rendered by enc for the owner. Caller must hold core.mu.
+func (p *preset) render(owner *sink, enc Encoder, gen uint64) []byte {
+	if p == nil {
+		return nil
+	}
+	var c *presetCache
+	for i := range p.cache {
+		if p.cache[i].owner == owner {
+			c = &p.cache[i]
+			break
+		}
+	}
+	if c == nil {
+		p.cache = append(p.cache, presetCache{owner: owner, gen: gen - 1})
+		c = &p.cache[len(p.cache)-1]
+	}
+	if c.gen != gen {
+		c.b = c.b[:0]
+		for i := 0; i < len(p.fields); i++ {
+			enc.Field(&c.b, p.fields[i])
+		}
+		c.gen = gen
+	}
+	return c.b
+}